@@ -0,0 +1,363 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"html"
+	"log"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/ingentingalls/rofi"
+	"github.com/ingentingalls/rofi-media/mpris"
+)
+
+const alarmsStateFile = "alarms.json"
+const alarmTimeLayout = "15:04"
+
+const alarmTickInterval = 10 * time.Second
+
+// Alarms missed by more than this, e.g. while the computer was off, are skipped
+const alarmGracePeriod = 15 * time.Minute
+
+// alarm starts a player, or opens a uri in it, at a time of day
+type alarm struct {
+	ID      int    `json:"id"`
+	Time    string `json:"time"`
+	Player  string `json:"player"`
+	URI     string `json:"uri,omitempty"`
+	Daily   bool   `json:"daily,omitempty"`
+	Enabled bool   `json:"enabled"`
+
+	Next time.Time `json:"next"`
+}
+
+// alarmState is stored on disk so alarms added from the command line or rofi reach the daemon,
+// and survive restarts
+type alarmState struct {
+	Alarms []alarm `json:"alarms"`
+}
+
+func loadAlarms() alarmState {
+	var s alarmState
+	if err := readState(statePath(alarmsStateFile), &s); err != nil {
+		log.Printf("Could not load alarms: %s", err)
+	}
+
+	return s
+}
+
+func saveAlarms(s alarmState) error {
+	if err := writeState(statePath(alarmsStateFile), s); err != nil {
+		return fmt.Errorf("alarm: %w", err)
+	}
+
+	return nil
+}
+
+// updateAlarms loads the alarms, lets update change them and saves them if update returns true.
+// Rofi, the command line and the daemon all change the alarms, so the file is locked meanwhile.
+func updateAlarms(update func(s *alarmState) (bool, error)) error {
+	unlock, err := lockState(statePath(alarmsStateFile))
+	if err != nil {
+		return fmt.Errorf("alarm: %w", err)
+	}
+	defer unlock()
+
+	// A file that can't be read isn't replaced, or every alarm in it would be lost
+	var s alarmState
+	if err := readState(statePath(alarmsStateFile), &s); err != nil {
+		return fmt.Errorf("alarm: %w", err)
+	}
+
+	changed, err := update(&s)
+	if err != nil || !changed {
+		return err
+	}
+
+	return saveAlarms(s)
+}
+
+// nextAlarm returns the first time after t that is at the time of day hhmm
+func nextAlarm(hhmm string, t time.Time) (time.Time, error) {
+	at, err := time.Parse(alarmTimeLayout, hhmm)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("alarm: expected a time like 07:30: %w", err)
+	}
+
+	next := time.Date(t.Year(), t.Month(), t.Day(), at.Hour(), at.Minute(), 0, 0, t.Location())
+	if !next.After(t) {
+		next = time.Date(t.Year(), t.Month(), t.Day()+1, at.Hour(), at.Minute(), 0, 0, t.Location())
+	}
+
+	return next, nil
+}
+
+func addAlarm(a alarm) (alarm, error) {
+	next, err := nextAlarm(a.Time, time.Now())
+	if err != nil {
+		return a, err
+	}
+	a.Next = next
+	a.Enabled = true
+
+	err = updateAlarms(func(s *alarmState) (bool, error) {
+		for _, existing := range s.Alarms {
+			if existing.ID >= a.ID {
+				a.ID = existing.ID + 1
+			}
+		}
+		if a.ID == 0 {
+			a.ID = 1
+		}
+
+		s.Alarms = append(s.Alarms, a)
+		return true, nil
+	})
+
+	return a, err
+}
+
+func removeAlarm(id int) error {
+	return updateAlarms(func(s *alarmState) (bool, error) {
+		for i, a := range s.Alarms {
+			if a.ID == id {
+				s.Alarms = append(s.Alarms[:i], s.Alarms[i+1:]...)
+				return true, nil
+			}
+		}
+
+		return false, fmt.Errorf("alarm: no alarm with id %d", id)
+	})
+}
+
+// enableAlarm turns an alarm on or off. Enabling it schedules it for the next time it comes around.
+func enableAlarm(id int, enabled bool) error {
+	return switchAlarm(id, func(bool) bool { return enabled })
+}
+
+func toggleAlarm(id int) error {
+	return switchAlarm(id, func(enabled bool) bool { return !enabled })
+}
+
+// switchAlarm decides whether an alarm is on from whether it was, while the alarms are locked
+func switchAlarm(id int, enable func(enabled bool) bool) error {
+	return updateAlarms(func(s *alarmState) (bool, error) {
+		for i, a := range s.Alarms {
+			if a.ID != id {
+				continue
+			}
+
+			enabled := enable(a.Enabled)
+			if enabled {
+				next, err := nextAlarm(a.Time, time.Now())
+				if err != nil {
+					return false, err
+				}
+				s.Alarms[i].Next = next
+			}
+			s.Alarms[i].Enabled = enabled
+			return true, nil
+		}
+
+		return false, fmt.Errorf("alarm: no alarm with id %d", id)
+	})
+}
+
+// watchAlarms checks the alarms in the daemon and starts the players when they go off
+func watchAlarms(players *playerList) {
+	go func() {
+		for range time.Tick(alarmTickInterval) {
+			var due []alarm
+
+			err := updateAlarms(func(s *alarmState) (bool, error) {
+				now := time.Now()
+
+				var changed bool
+				for i, a := range s.Alarms {
+					if !a.Enabled || a.Next.After(now) {
+						continue
+					}
+
+					if now.Sub(a.Next) <= alarmGracePeriod {
+						due = append(due, a)
+					} else {
+						log.Printf("Skipping alarm %d, it was missed at %s\n", a.ID, a.Next.Format(time.Stamp))
+					}
+
+					if a.Daily {
+						next, err := nextAlarm(a.Time, now)
+						if err != nil {
+							return false, err
+						}
+						s.Alarms[i].Next = next
+					} else {
+						s.Alarms[i].Enabled = false
+					}
+					changed = true
+				}
+
+				return changed, nil
+			})
+			if err != nil {
+				log.Printf("Could not check alarms: %s", err)
+			}
+
+			for _, a := range due {
+				go startAlarm(players.All(), a)
+			}
+		}
+	}()
+}
+
+func startAlarm(players []mpris.Player, a alarm) {
+	p := findPlayer(players, a.Player)
+	if p == nil {
+		log.Printf("Could not start alarm %d: %s isn't running", a.ID, a.Player)
+		return
+	}
+
+	log.Printf("Alarm %d went off, starting %s\n", a.ID, p.Name)
+
+	start := p.Play
+	if a.URI != "" {
+		start = func() error { return p.OpenUri(a.URI) }
+	} else if p.IsPlaying() {
+		return
+	}
+
+	if err := fadeIn(*p, getConfig().Alarm.Ramp, start); err != nil {
+		log.Printf("Could not start alarm %d (%s): %s", a.ID, p.Name, err)
+	}
+}
+
+func formatAlarm(a alarm) string {
+	s := a.Time + " " + a.Player
+	if a.URI != "" {
+		s += " " + a.URI
+	}
+
+	return s
+}
+
+func formatAlarmSchedule(a alarm) string {
+	if !a.Enabled {
+		return "off"
+	}
+
+	s := "once"
+	if a.Daily {
+		s = "daily"
+	}
+
+	return s + ", next " + a.Next.Format("Mon 15:04")
+}
+
+func showAlarmAction() []rofi.Option {
+	return []rofi.Option{{
+		Label: "Alarms",
+		Cmds:  []string{"alarms"},
+		Icon:  getConfig().Icons.Alarm,
+	}}
+}
+
+// showAlarms lists the alarms. Selecting one turns it on or off, and the second command removes it
+func showAlarms() []rofi.Option {
+	c := getConfig()
+
+	var opts []rofi.Option
+	for _, a := range loadAlarms().Alarms {
+		opts = append(opts, rofi.Option{
+			Label:    html.EscapeString(formatAlarm(a)),
+			Category: fmt.Sprintf("<span color=\"%s\">%s</span>", html.EscapeString(c.Colors.Category), html.EscapeString(formatAlarmSchedule(a))),
+			Icon:     c.Icons.Alarm,
+			Value:    strconv.Itoa(a.ID),
+			Cmds:     []string{"toggleAlarm", "removeAlarm"},
+
+			IsMultiline: true,
+			UseMarkup:   true,
+		})
+	}
+
+	opts = append(opts, rofi.Option{
+		Label: "Back",
+		Cmds:  []string{"showAll"},
+		Icon:  c.Icons.Back,
+	})
+
+	return opts
+}
+
+func alarmsMessage() string {
+	if daemon != nil && !daemon.IsRunning() {
+		return "Alarms only go off while the daemon is running"
+	}
+
+	return "Add alarms with: rofi-media alarm add 07:30 spotify"
+}
+
+// runAlarm manages the alarms from the command line
+func runAlarm(args []string) {
+	cmd := "list"
+	if len(args) > 0 {
+		cmd, args = args[0], args[1:]
+	}
+
+	switch cmd {
+	case "list":
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		for _, a := range loadAlarms().Alarms {
+			fmt.Fprintf(w, "%d\t%s\t%s\n", a.ID, formatAlarm(a), formatAlarmSchedule(a))
+		}
+		w.Flush()
+
+	case "add":
+		fs := flag.NewFlagSet("alarm add", flag.ExitOnError)
+		daily := fs.Bool("daily", false, "go off every day")
+		uri := fs.String("uri", "", "uri to open instead of resuming the player")
+		fs.Usage = func() {
+			fmt.Fprintln(fs.Output(), "usage: rofi-media alarm add [-daily] [-uri uri] HH:MM player")
+			fs.PrintDefaults()
+		}
+		fs.Parse(args)
+		if fs.NArg() != 2 {
+			fs.Usage()
+			os.Exit(2)
+		}
+
+		a, err := addAlarm(alarm{Time: fs.Arg(0), Player: fs.Arg(1), URI: *uri, Daily: *daily})
+		if err != nil {
+			log.Fatalf("%s", err)
+		}
+		fmt.Printf("%d\t%s\t%s\n", a.ID, formatAlarm(a), formatAlarmSchedule(a))
+
+		if conn, err := dbus.SessionBus(); err == nil && !newDaemonClient(conn).IsRunning() {
+			log.Printf("Alarms only go off while the daemon is running")
+		}
+
+	case "remove", "enable", "disable":
+		if len(args) != 1 {
+			log.Fatalf("usage: rofi-media alarm %s id", cmd)
+		}
+		id, err := strconv.Atoi(args[0])
+		if err != nil {
+			log.Fatalf("alarm: invalid id %q", args[0])
+		}
+
+		switch cmd {
+		case "remove":
+			err = removeAlarm(id)
+		default:
+			err = enableAlarm(id, cmd == "enable")
+		}
+		if err != nil {
+			log.Fatalf("%s", err)
+		}
+
+	default:
+		log.Fatalf("alarm: unknown command %q", cmd)
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+func TestUpdateAlarmsKeepsUnreadableFile(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+
+	corrupt := []byte(`{"alarms": [{"id": 1, "time": "07:30"`)
+	if err := os.MkdirAll(stateDir(), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(statePath(alarmsStateFile), corrupt, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := addAlarm(alarm{Time: "08:00", Player: "spotify"}); err == nil {
+		t.Error("addAlarm() succeeded on an unreadable file")
+	}
+
+	data, err := os.ReadFile(statePath(alarmsStateFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != string(corrupt) {
+		t.Errorf("addAlarm() replaced the unreadable file with %s", data)
+	}
+}
+
+func TestToggleAlarm(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+
+	a, err := addAlarm(alarm{Time: "08:00", Player: "spotify"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []bool{false, true, false} {
+		if err := toggleAlarm(a.ID); err != nil {
+			t.Fatal(err)
+		}
+		if got := loadAlarms().Alarms[0].Enabled; got != want {
+			t.Errorf("toggleAlarm() enabled = %v, want %v", got, want)
+		}
+	}
+
+	if err := toggleAlarm(a.ID + 1); err == nil {
+		t.Error("toggleAlarm() succeeded for a missing alarm")
+	}
+}
@@ -0,0 +1,18 @@
+//go:build linux
+
+package art
+
+import (
+	"io/fs"
+	"syscall"
+	"time"
+)
+
+// lastUse is the access time of a cached file, which LookupKey sets
+func lastUse(info fs.FileInfo) time.Time {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return time.Unix(st.Atim.Unix())
+	}
+
+	return info.ModTime()
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package art
+
+import (
+	"io/fs"
+	"time"
+)
+
+// lastUse falls back to when a cached file was fetched where the access time can't be read
+func lastUse(info fs.FileInfo) time.Time {
+	return info.ModTime()
+}
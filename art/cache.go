@@ -0,0 +1,287 @@
+package art
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const maxArtSize = 20 << 20
+const cacheFileExt = ".img"
+
+// Cache stores album art on disk keyed by a hash of the art url.
+// Files are evicted when they were fetched longer than maxAge ago, and the least recently
+// used files are evicted when the cache grows beyond maxSize.
+// The modification time of a file is when it was fetched, and the access time when it was last used.
+type Cache struct {
+	dir     string
+	maxSize int64
+	maxAge  time.Duration
+
+	mu sync.Mutex
+}
+
+func NewCache(dir string, maxSize int64, maxAge time.Duration) *Cache {
+	return &Cache{dir: dir, maxSize: maxSize, maxAge: maxAge}
+}
+
+func DefaultCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+
+	return path.Join(dir, "rofi-media", "art")
+}
+
+func (c *Cache) Configure(dir string, maxSize int64, maxAge time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.dir = dir
+	c.maxSize = maxSize
+	c.maxAge = maxAge
+}
+
+func Key(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return hex.EncodeToString(sum[:])
+}
+
+func (c *Cache) path(key string) string {
+	// Needs any extension to work. doesnt matter which
+	return path.Join(c.dir, key+cacheFileExt)
+}
+
+// Lookup returns the path of the cached art for url if it exists and hasn't expired
+func (c *Cache) Lookup(url string) (string, bool) {
+	return c.LookupKey(Key(url))
+}
+
+func (c *Cache) LookupKey(key string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	p := c.path(key)
+	info, err := os.Stat(p)
+	if err != nil || !info.Mode().IsRegular() || info.Size() == 0 {
+		return "", false
+	}
+
+	if c.maxAge > 0 && time.Since(info.ModTime()) > c.maxAge {
+		os.Remove(p)
+		return "", false
+	}
+
+	// Set explicitly, since noatime mounts don't update it. The modification time is kept for maxAge
+	if err := os.Chtimes(p, time.Now(), info.ModTime()); err != nil {
+		log.Printf("art.Cache: Could not touch %s: %s\n", p, err)
+	}
+
+	return p, true
+}
+
+// Store writes the image read from r into the cache.
+// The content is sniffed and rejected unless it is an image.
+func (c *Cache) Store(url string, r io.Reader) (string, error) {
+	return c.StoreKey(Key(url), r)
+}
+
+func (c *Cache) StoreKey(key string, r io.Reader) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := os.MkdirAll(c.dir, os.ModePerm); err != nil {
+		return "", fmt.Errorf("art.Store: Error while creating path: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(c.dir, key+".*.tmp")
+	if err != nil {
+		return "", fmt.Errorf("art.Store: Error while creating file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(r, head)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return "", fmt.Errorf("art.Store: Error while reading: %w", err)
+	}
+	head = head[:n]
+
+	if !strings.HasPrefix(http.DetectContentType(head), "image/") {
+		return "", fmt.Errorf("art.Store: %w", ErrNotAnImage)
+	}
+
+	if _, err := tmp.Write(head); err != nil {
+		return "", fmt.Errorf("art.Store: Error while writing file: %w", err)
+	}
+
+	written, err := io.Copy(tmp, io.LimitReader(r, maxArtSize-int64(n)+1))
+	if err != nil {
+		return "", fmt.Errorf("art.Store: Error while writing file: %w", err)
+	}
+	if written+int64(n) > maxArtSize {
+		return "", fmt.Errorf("art.Store: %w", ErrTooLarge)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return "", fmt.Errorf("art.Store: Error while writing file: %w", err)
+	}
+
+	p := c.path(key)
+	if err := os.Rename(tmp.Name(), p); err != nil {
+		return "", fmt.Errorf("art.Store: Error while moving file into place: %w", err)
+	}
+
+	c.evict()
+
+	return p, nil
+}
+
+// LookupMeta returns small non-image data that is stored alongside the art
+func (c *Cache) LookupMeta(key, ext string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	data, err := os.ReadFile(path.Join(c.dir, key+ext))
+	if err != nil || len(data) == 0 {
+		return nil, false
+	}
+
+	return data, true
+}
+
+func (c *Cache) StoreMeta(key, ext string, data []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := os.MkdirAll(c.dir, os.ModePerm); err != nil {
+		return fmt.Errorf("art.StoreMeta: Error while creating path: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(c.dir, key+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("art.StoreMeta: Error while creating file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	if _, err := tmp.Write(data); err != nil {
+		return fmt.Errorf("art.StoreMeta: Error while writing file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("art.StoreMeta: Error while writing file: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), path.Join(c.dir, key+ext)); err != nil {
+		return fmt.Errorf("art.StoreMeta: Error while moving file into place: %w", err)
+	}
+
+	return nil
+}
+
+func (c *Cache) Evict() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.evict()
+}
+
+// isCacheFile reports whether name is one the cache writes: a key, optionally with a
+// thumbnail size, followed by the art or meta extension or the temporary file suffix
+func isCacheFile(name string) bool {
+	if len(name) < sha256.Size*2 {
+		return false
+	}
+	if _, err := hex.DecodeString(name[:sha256.Size*2]); err != nil {
+		return false
+	}
+
+	rest := name[sha256.Size*2:]
+	if strings.HasPrefix(rest, "-") {
+		size := strings.TrimLeft(rest[1:], "0123456789")
+		if len(size) == len(rest)-1 {
+			return false
+		}
+		rest = size
+	}
+
+	switch {
+	case rest == cacheFileExt, rest == colorsExt:
+		return true
+	case len(rest) > len("..tmp") && strings.HasPrefix(rest, ".") && strings.HasSuffix(rest, ".tmp"):
+		random := rest[1 : len(rest)-len(".tmp")]
+		return strings.Trim(random, "0123456789") == ""
+	}
+
+	return false
+}
+
+func (c *Cache) evict() {
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return
+	}
+
+	type cacheFile struct {
+		path    string
+		size    int64
+		lastUse time.Time
+	}
+
+	var files []cacheFile
+	var total int64
+	for _, e := range entries {
+		// The directory is configurable, so other files may live next to the cache
+		if !e.Type().IsRegular() || !isCacheFile(e.Name()) {
+			continue
+		}
+
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+
+		p := path.Join(c.dir, e.Name())
+		isStale := c.maxAge > 0 && time.Since(info.ModTime()) > c.maxAge
+		// Leftovers from interrupted writes
+		isOrphan := strings.HasSuffix(e.Name(), ".tmp") && time.Since(info.ModTime()) > time.Hour
+		if isStale || isOrphan {
+			os.Remove(p)
+			continue
+		}
+
+		files = append(files, cacheFile{path: p, size: info.Size(), lastUse: lastUse(info)})
+		total += info.Size()
+	}
+
+	if c.maxSize <= 0 || total <= c.maxSize {
+		return
+	}
+
+	sort.Slice(files, func(a, b int) bool {
+		return files[a].lastUse.Before(files[b].lastUse)
+	})
+
+	for _, f := range files {
+		if total <= c.maxSize {
+			break
+		}
+
+		if err := os.Remove(f.path); err != nil {
+			log.Printf("art.Cache: Could not evict %s: %s\n", f.path, err)
+			continue
+		}
+		total -= f.size
+	}
+}
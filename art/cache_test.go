@@ -0,0 +1,139 @@
+package art
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testPNG = append([]byte("\x89PNG\r\n\x1a\n"), bytes.Repeat([]byte{0}, 100)...)
+
+func storeTestArt(t *testing.T, c *Cache, url string, fetched time.Time) string {
+	t.Helper()
+
+	p, err := c.Store(url, bytes.NewReader(testPNG))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(p, fetched, fetched); err != nil {
+		t.Fatal(err)
+	}
+
+	return p
+}
+
+func TestCacheLookupKeepsFetchTime(t *testing.T) {
+	c := NewCache(t.TempDir(), 0, time.Hour)
+
+	fetched := time.Now().Add(-30 * time.Minute)
+	p := storeTestArt(t, c, "https://example.com/a.png", fetched)
+
+	if _, ok := c.Lookup("https://example.com/a.png"); !ok {
+		t.Fatal("Lookup() missed fresh art")
+	}
+
+	info, err := os.Stat(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if time.Since(info.ModTime()) < 29*time.Minute {
+		t.Errorf("Lookup() changed the fetch time to %s, want %s", info.ModTime(), fetched)
+	}
+
+	// Frequently viewed art still expires
+	expired := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(p, time.Now(), expired); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.Lookup("https://example.com/a.png"); ok {
+		t.Error("Lookup() returned expired art")
+	}
+}
+
+func TestCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	dir := t.TempDir()
+	c := NewCache(dir, 0, 0)
+
+	now := time.Now()
+	storeTestArt(t, c, "https://example.com/old.png", now.Add(-2*time.Hour))
+	storeTestArt(t, c, "https://example.com/new.png", now.Add(-time.Hour))
+
+	// The older art is used, so the newer one is the least recently used
+	if _, ok := c.Lookup("https://example.com/old.png"); !ok {
+		t.Fatal("Lookup() missed old.png")
+	}
+
+	c.Configure(dir, int64(len(testPNG)), 0)
+	c.Evict()
+
+	if _, ok := c.Lookup("https://example.com/old.png"); !ok {
+		t.Error("Evict() removed the recently used art")
+	}
+	if _, ok := c.Lookup("https://example.com/new.png"); ok {
+		t.Error("Evict() kept the least recently used art")
+	}
+}
+
+func TestCacheEvictKeepsForeignFiles(t *testing.T) {
+	dir := t.TempDir()
+	c := NewCache(dir, 0, time.Hour)
+
+	old := time.Now().Add(-2 * time.Hour)
+	foreign := []string{"holiday.jpg", "notes.tmp", Key("a") + ".txt", strings.Repeat("z", 64) + ".img"}
+	for _, name := range foreign {
+		p := filepath.Join(dir, name)
+		if err := os.WriteFile(p, testPNG, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(p, old, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stale := storeTestArt(t, c, "https://example.com/stale.png", old)
+
+	// Over budget as well, so nothing of the cache's own would be kept
+	c.Configure(dir, 1, time.Hour)
+	c.Evict()
+
+	if _, err := os.Stat(stale); err == nil {
+		t.Error("Evict() kept stale art")
+	}
+	for _, name := range foreign {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("Evict() removed %s: %s", name, err)
+		}
+	}
+}
+
+func TestIsCacheFile(t *testing.T) {
+	key := Key("https://example.com/a.png")
+
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{key + cacheFileExt, true},
+		{key + "-128" + cacheFileExt, true},
+		{key + colorsExt, true},
+		{key + ".123456.tmp", true},
+		{key + "-128.123456.tmp", true},
+		{key, false},
+		{key + "-" + cacheFileExt, false},
+		{key + "-12a" + cacheFileExt, false},
+		{key + ".tmp", false},
+		{key + ".abc.tmp", false},
+		{key[:63] + cacheFileExt, false},
+		{"cover.img", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		if got := isCacheFile(tt.name); got != tt.want {
+			t.Errorf("isCacheFile(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package art
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"os"
+	"sort"
+	"strings"
+)
+
+const colorsExt = ".colors"
+
+// Pixels are sampled on a grid so large images don't take long to scan
+const maxColorSamples = 128
+
+type Colors struct {
+	// Dominant is the most common colour
+	Dominant string
+	// Accent is the most common saturated colour, or Dominant if there is none
+	Accent string
+}
+
+// Colors returns the colours of the image at p if they have been computed.
+// Otherwise it schedules them to be computed and returns false.
+func (f *Fetcher) Colors(p string) (Colors, bool) {
+	key := Key("colors:" + p)
+
+	if data, ok := f.cache.LookupMeta(key, colorsExt); ok {
+		if c, err := parseColors(data); err == nil {
+			return c, true
+		}
+	}
+
+	f.schedule(job{
+		key:  key,
+		name: p,
+		run: func(ctx context.Context) error {
+			c, err := colorsOf(p)
+			if err != nil {
+				return err
+			}
+
+			return f.cache.StoreMeta(key, colorsExt, []byte(c.Dominant+" "+c.Accent))
+		},
+	})
+
+	return Colors{}, false
+}
+
+func parseColors(data []byte) (Colors, error) {
+	fields := strings.Fields(string(data))
+	if len(fields) != 2 {
+		return Colors{}, fmt.Errorf("art.parseColors: invalid colors %q", data)
+	}
+
+	return Colors{Dominant: fields[0], Accent: fields[1]}, nil
+}
+
+func colorsOf(p string) (c Colors, err error) {
+	file, err := os.Open(p)
+	if err != nil {
+		return c, err
+	}
+	defer file.Close()
+
+	cfg, _, err := image.DecodeConfig(file)
+	if err != nil {
+		return c, fmt.Errorf("art.colorsOf: %w: %s", ErrDecode, err)
+	}
+	if cfg.Width > maxImageDimension || cfg.Height > maxImageDimension || cfg.Width*cfg.Height > maxImagePixels {
+		return c, fmt.Errorf("art.colorsOf: %w: %dx%d", ErrTooLarge, cfg.Width, cfg.Height)
+	}
+
+	if _, err := file.Seek(0, 0); err != nil {
+		return c, err
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("art.colorsOf: %w: %v", ErrDecode, r)
+		}
+	}()
+
+	img, _, err := image.Decode(file)
+	if err != nil {
+		return c, fmt.Errorf("art.colorsOf: %w: %s", ErrDecode, err)
+	}
+
+	dominant, accent := dominantColors(img)
+
+	return Colors{Dominant: hexColor(dominant), Accent: hexColor(accent)}, nil
+}
+
+type colorBucket struct {
+	count   int
+	r, g, b int
+}
+
+func (cb colorBucket) average() [3]int {
+	return [3]int{cb.r / cb.count, cb.g / cb.count, cb.b / cb.count}
+}
+
+// dominantColors quantizes the image to 4 bits per channel and picks the most common bucket.
+// The accent is the bucket with the highest count weighted by saturation, skipping
+// colours that are too dark or too bright to stand out.
+// Ties go to the lowest bucket, so the same image always gets the same colours.
+func dominantColors(img image.Image) ([3]int, [3]int) {
+	b := img.Bounds()
+
+	stepX := b.Dx()/maxColorSamples + 1
+	stepY := b.Dy()/maxColorSamples + 1
+
+	buckets := map[int]*colorBucket{}
+	for y := b.Min.Y; y < b.Max.Y; y += stepY {
+		for x := b.Min.X; x < b.Max.X; x += stepX {
+			r, g, bl, a := img.At(x, y).RGBA()
+			if a < 0x8000 {
+				continue
+			}
+
+			r8, g8, b8 := int(r>>8), int(g>>8), int(bl>>8)
+			idx := (r8>>4)<<8 | (g8>>4)<<4 | b8>>4
+
+			cb, ok := buckets[idx]
+			if !ok {
+				cb = &colorBucket{}
+				buckets[idx] = cb
+			}
+			cb.count++
+			cb.r += r8
+			cb.g += g8
+			cb.b += b8
+		}
+	}
+
+	keys := make([]int, 0, len(buckets))
+	for idx := range buckets {
+		keys = append(keys, idx)
+	}
+	sort.Ints(keys)
+
+	var dominant, accent *colorBucket
+	var accentScore float64
+	for _, idx := range keys {
+		cb := buckets[idx]
+		if dominant == nil || cb.count > dominant.count {
+			dominant = cb
+		}
+
+		s, v := saturationValue(cb.average())
+		if s < 0.3 || v < 0.3 || v > 0.95 {
+			continue
+		}
+
+		if score := float64(cb.count) * s; score > accentScore {
+			accent = cb
+			accentScore = score
+		}
+	}
+
+	if dominant == nil {
+		return [3]int{}, [3]int{}
+	}
+	if accent == nil {
+		accent = dominant
+	}
+
+	return dominant.average(), accent.average()
+}
+
+// saturationValue returns the saturation and value of an RGB colour in the HSV model
+func saturationValue(c [3]int) (float64, float64) {
+	max, min := c[0], c[0]
+	for _, v := range c[1:] {
+		if v > max {
+			max = v
+		}
+		if v < min {
+			min = v
+		}
+	}
+
+	if max == 0 {
+		return 0, 0
+	}
+
+	return float64(max-min) / float64(max), float64(max) / 255
+}
+
+func hexColor(c [3]int) string {
+	return fmt.Sprintf("#%02X%02X%02X", c[0], c[1], c[2])
+}
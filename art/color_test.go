@@ -0,0 +1,90 @@
+package art
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// newColorTestImage fills a 10x10 image, with colour(i) deciding the colour of the i-th pixel
+func newColorTestImage(colour func(i int) color.Color) image.Image {
+	img := image.NewNRGBA(image.Rect(0, 0, 10, 10))
+	for y := 0; y < 10; y++ {
+		for x := 0; x < 10; x++ {
+			img.Set(x, y, colour(y*10+x))
+		}
+	}
+
+	return img
+}
+
+// split colours the first n percent of the pixels a and the rest b
+func split(n int, a, b color.Color) func(i int) color.Color {
+	return func(i int) color.Color {
+		if i < n {
+			return a
+		}
+		return b
+	}
+}
+
+func TestDominantColors(t *testing.T) {
+	gray := color.NRGBA{R: 128, G: 128, B: 128, A: 255}
+	red := color.NRGBA{R: 200, A: 255}
+	blue := color.NRGBA{B: 200, A: 255}
+	pale := color.NRGBA{R: 200, G: 120, B: 120, A: 255}
+	white := color.NRGBA{R: 255, G: 255, B: 255, A: 255}
+	black := color.NRGBA{A: 255}
+	clear := color.NRGBA{R: 200}
+
+	tests := []struct {
+		name         string
+		img          image.Image
+		wantDominant string
+		wantAccent   string
+	}{
+		{"solid", newColorTestImage(split(100, red, red)), "#C80000", "#C80000"},
+		{"small saturated patch", newColorTestImage(split(90, gray, blue)), "#808080", "#0000C8"},
+		{"saturation outweighs count", newColorTestImage(split(60, pale, blue)), "#C87878", "#0000C8"},
+		{"too dark and too bright", newColorTestImage(split(70, black, white)), "#000000", "#000000"},
+		{"dark colours aren't accents", newColorTestImage(split(80, gray, color.NRGBA{R: 60, A: 255})), "#808080", "#808080"},
+		{"ties go to the lowest bucket", newColorTestImage(split(50, red, blue)), "#0000C8", "#0000C8"},
+		{"transparent pixels are skipped", newColorTestImage(split(70, clear, gray)), "#808080", "#808080"},
+		{"transparent", newColorTestImage(split(100, clear, clear)), "#000000", "#000000"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Map order changes between runs, so a tie would show up as a different colour
+			for i := 0; i < 20; i++ {
+				dominant, accent := dominantColors(tt.img)
+				if got := hexColor(dominant); got != tt.wantDominant {
+					t.Fatalf("dominantColors() dominant = %s, want %s", got, tt.wantDominant)
+				}
+				if got := hexColor(accent); got != tt.wantAccent {
+					t.Fatalf("dominantColors() accent = %s, want %s", got, tt.wantAccent)
+				}
+			}
+		})
+	}
+}
+
+func TestSaturationValue(t *testing.T) {
+	tests := []struct {
+		colour           [3]int
+		wantS, wantValue float64
+	}{
+		{[3]int{0, 0, 0}, 0, 0},
+		{[3]int{255, 255, 255}, 0, 1},
+		{[3]int{255, 0, 0}, 1, 1},
+		{[3]int{200, 100, 100}, 0.5, 200.0 / 255},
+		{[3]int{51, 51, 102}, 0.5, 0.4},
+	}
+
+	for _, tt := range tests {
+		s, v := saturationValue(tt.colour)
+		if s != tt.wantS || v != tt.wantValue {
+			t.Errorf("saturationValue(%v) = %v, %v, want %v, %v", tt.colour, s, v, tt.wantS, tt.wantValue)
+		}
+	}
+}
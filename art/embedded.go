@@ -0,0 +1,158 @@
+package art
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var ErrNoPicture = errors.New("no picture found")
+
+// Picture types as defined by ID3v2 APIC and FLAC PICTURE
+const pictureTypeFrontCover = 3
+
+// Covers are picked in this order when there is no embedded picture
+var folderImageNames = []string{
+	"cover.jpg", "cover.jpeg", "cover.png",
+	"folder.jpg", "folder.jpeg", "folder.png",
+	"front.jpg", "front.jpeg", "front.png",
+	"albumart.jpg", "albumart.png",
+}
+
+type picture struct {
+	kind uint32
+	mime string
+	data []byte
+}
+
+// choosePicture prefers the front cover and falls back to the first picture
+func choosePicture(pictures []picture) (picture, error) {
+	for _, p := range pictures {
+		if p.kind == pictureTypeFrontCover && len(p.data) > 0 {
+			return p, nil
+		}
+	}
+
+	for _, p := range pictures {
+		if len(p.data) > 0 {
+			return p, nil
+		}
+	}
+
+	return picture{}, ErrNoPicture
+}
+
+func mediaPath(mediaURL string) (string, error) {
+	if schemeOf(mediaURL) != schemeFile {
+		return "", ErrUnsupportedScheme
+	}
+
+	u, err := url.Parse(mediaURL)
+	if err != nil {
+		return "", err
+	}
+
+	p := filepath.Clean(u.Path)
+	if !filepath.IsAbs(p) {
+		return "", fmt.Errorf("path is not absolute: %s", p)
+	}
+
+	return p, nil
+}
+
+// extractCover reads the embedded cover of a local media file.
+// Falls back to a cover image in the same directory.
+func extractCover(p string) (io.Reader, error) {
+	pic, err := extractEmbedded(p)
+	if err == nil {
+		return bytes.NewReader(pic.data), nil
+	}
+
+	folderImage, folderErr := findFolderImage(filepath.Dir(p))
+	if folderErr != nil {
+		return nil, fmt.Errorf("art.extractCover: %w", err)
+	}
+
+	data, err := os.ReadFile(folderImage)
+	if err != nil {
+		return nil, fmt.Errorf("art.extractCover: %w", err)
+	}
+
+	return bytes.NewReader(data), nil
+}
+
+func extractEmbedded(p string) (picture, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return picture{}, err
+	}
+	defer f.Close()
+
+	head := make([]byte, 12)
+	if _, err := io.ReadFull(f, head); err != nil {
+		return picture{}, ErrNoPicture
+	}
+
+	var pictures []picture
+	switch {
+	case bytes.HasPrefix(head, []byte("fLaC")):
+		pictures, err = readFLACPictures(f, 0)
+
+	case bytes.HasPrefix(head, []byte("ID3")):
+		pictures, err = readID3Pictures(f)
+		// FLAC files are sometimes prefixed with an ID3 tag
+		if err == nil && len(pictures) == 0 {
+			if offset, ok := flacAfterID3(f); ok {
+				pictures, err = readFLACPictures(f, offset)
+			}
+		}
+
+	case bytes.HasPrefix(head, []byte("OggS")):
+		pictures, err = readOggPictures(f)
+
+	case bytes.Equal(head[4:8], []byte("ftyp")):
+		var info os.FileInfo
+		if info, err = f.Stat(); err == nil {
+			pictures, err = readMP4Pictures(f, info.Size())
+		}
+
+	default:
+		return picture{}, ErrNoPicture
+	}
+
+	if err != nil {
+		return picture{}, err
+	}
+
+	return choosePicture(pictures)
+}
+
+func findFolderImage(dir string) (string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+
+	names := map[string]string{}
+	for _, e := range entries {
+		if e.Type().IsRegular() {
+			names[strings.ToLower(e.Name())] = e.Name()
+		}
+	}
+
+	for _, candidate := range folderImageNames {
+		if name, ok := names[candidate]; ok {
+			p := filepath.Join(dir, name)
+			if validateImageFile(p) == nil {
+				return p, nil
+			}
+		}
+	}
+
+	return "", ErrNoPicture
+}
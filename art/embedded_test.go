@@ -0,0 +1,113 @@
+package art
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testCover contains 0xff bytes, so unsynchronisation changes it
+var testCover = []byte("\xff\xd8\xff\xe0\x00cover\xff\x00data")
+
+func equalPictures(a, b []picture) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].kind != b[i].kind || a[i].mime != b[i].mime || !bytes.Equal(a[i].data, b[i].data) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func be32(n int) []byte {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, uint32(n))
+	return b
+}
+
+func le32(n int) []byte {
+	b := make([]byte, 4)
+	binary.LittleEndian.PutUint32(b, uint32(n))
+	return b
+}
+
+func concat(parts ...[]byte) []byte {
+	var b []byte
+	for _, p := range parts {
+		b = append(b, p...)
+	}
+
+	return b
+}
+
+func TestChoosePicture(t *testing.T) {
+	back := picture{kind: 4, mime: "image/png", data: []byte("back")}
+	front := picture{kind: pictureTypeFrontCover, mime: "image/png", data: []byte("front")}
+	empty := picture{kind: pictureTypeFrontCover}
+
+	tests := []struct {
+		name     string
+		pictures []picture
+		want     picture
+		wantErr  bool
+	}{
+		{"front cover first", []picture{back, front}, front, false},
+		{"falls back to the first", []picture{back}, back, false},
+		{"skips empty pictures", []picture{empty, back}, back, false},
+		{"none", nil, picture{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := choosePicture(tt.pictures)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("choosePicture() error = %v, want error %v", err, tt.wantErr)
+			}
+			if !equalPictures([]picture{got}, []picture{tt.want}) {
+				t.Errorf("choosePicture() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractEmbedded(t *testing.T) {
+	cover := picture{kind: pictureTypeFrontCover, mime: "image/jpeg", data: testCover}
+	flac := flacStream(flacBlock(flacBlockTypePicture, true, pictureBlock(cover)))
+
+	tests := []struct {
+		name    string
+		data    []byte
+		want    picture
+		wantErr bool
+	}{
+		{"flac", flac, cover, false},
+		{"flac after an id3 tag", append(id3Tag(3, 0, id3Frame(3, "TIT2", 0, []byte("\x00title"))), flac...), cover, false},
+		{"id3", id3Tag(3, 0, id3Frame(3, "APIC", 0, apicBody(cover))), cover, false},
+		{"ogg", oggStream(1, 255, []byte("\x01vorbis"), concat(vorbisCommentPrefix, vorbisComment("METADATA_BLOCK_PICTURE="+base64Picture(cover)))), cover, false},
+		{"mp4", mp4File(mp4Data(mp4DataTypeJPEG, testCover)), picture{kind: pictureTypeFrontCover, mime: "image/jpeg", data: testCover}, false},
+		{"unknown format", []byte("RIFF\x00\x00\x00\x00WAVEfmt "), picture{}, true},
+		{"too short", []byte("fLa"), picture{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := filepath.Join(t.TempDir(), "track")
+			if err := os.WriteFile(p, tt.data, 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := extractEmbedded(p)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("extractEmbedded() error = %v, want error %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !equalPictures([]picture{got}, []picture{tt.want}) {
+				t.Errorf("extractEmbedded() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,375 @@
+package art
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+const retryFailedAfter = time.Minute
+const queueSize = 64
+
+var (
+	ErrNotAnImage = errors.New("not an image")
+	ErrTooLarge   = errors.New("image is too large")
+	ErrQueueFull  = errors.New("queue is full")
+)
+
+type job struct {
+	key  string
+	name string
+	run  func(ctx context.Context) error
+	done chan struct{}
+}
+
+// Fetcher downloads and extracts album art in the background.
+// Fetches for the same art are deduplicated while they are in flight.
+type Fetcher struct {
+	cache   *Cache
+	client  *http.Client
+	timeout time.Duration
+	onReady func()
+
+	iconSize int
+	jobs     chan job
+
+	pending map[string]chan struct{}
+	failed  map[string]time.Time
+	mu      sync.Mutex
+}
+
+func NewFetcher(cache *Cache, workers int, timeout time.Duration, onReady func()) *Fetcher {
+	f := &Fetcher{
+		cache:   cache,
+		client:  &http.Client{},
+		timeout: timeout,
+		onReady: onReady,
+		jobs:    make(chan job, queueSize),
+		pending: map[string]chan struct{}{},
+		failed:  map[string]time.Time{},
+	}
+
+	for i := 0; i < workers; i++ {
+		go f.work()
+	}
+
+	return f
+}
+
+// SetIconSize sets the size of the thumbnails handed out by Get and GetFromMedia.
+// Thumbnails are disabled when size is zero.
+func (f *Fetcher) SetIconSize(size int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.iconSize = size
+}
+
+func (f *Fetcher) getIconSize() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.iconSize
+}
+
+// Get returns the local path of the art if it's already cached.
+// Otherwise it schedules a fetch and returns an empty string.
+// Art that is available locally (file: and data: urls) is resolved right away.
+// A thumbnail is returned when one has been created.
+func (f *Fetcher) Get(url string) string {
+	if schemeOf(url) != schemeHTTP {
+		return f.getLocal(url, true)
+	}
+
+	return f.get(f.httpJob(url))
+}
+
+// Wait is like Get but blocks until the art has been fetched or the fetch timed out.
+// It always returns the full size art.
+func (f *Fetcher) Wait(url string) string {
+	if schemeOf(url) != schemeHTTP {
+		return f.getLocal(url, false)
+	}
+
+	return f.wait(f.httpJob(url))
+}
+
+// GetFromMedia returns the cover embedded in a local media file (file: urls), if it's already cached.
+// Otherwise it schedules an extraction and returns an empty string.
+func (f *Fetcher) GetFromMedia(mediaURL string) string {
+	if schemeOf(mediaURL) != schemeFile {
+		return ""
+	}
+
+	return f.get(f.mediaJob(mediaURL))
+}
+
+// WaitFromMedia is like GetFromMedia but blocks until the cover has been extracted.
+func (f *Fetcher) WaitFromMedia(mediaURL string) string {
+	if schemeOf(mediaURL) != schemeFile {
+		return ""
+	}
+
+	return f.wait(f.mediaJob(mediaURL))
+}
+
+func (f *Fetcher) httpJob(url string) job {
+	key := Key(url)
+
+	return job{
+		key:  key,
+		name: url,
+		run: func(ctx context.Context) error {
+			r, err := f.fetch(ctx, url)
+			if err != nil {
+				return err
+			}
+			defer r.Close()
+
+			return f.storeOriginal(key, url, r)
+		},
+	}
+}
+
+func (f *Fetcher) mediaJob(mediaURL string) job {
+	key := Key("embedded:" + mediaURL)
+
+	return job{
+		key:  key,
+		name: mediaURL,
+		run: func(ctx context.Context) error {
+			p, err := mediaPath(mediaURL)
+			if err != nil {
+				return err
+			}
+
+			r, err := extractCover(p)
+			if err != nil {
+				return err
+			}
+
+			return f.storeOriginal(key, mediaURL, r)
+		},
+	}
+}
+
+func (f *Fetcher) thumbnailJob(key, src string, size int) job {
+	return job{
+		key:  thumbnailKey(key, size),
+		name: src,
+		run: func(ctx context.Context) error {
+			return f.storeThumbnail(key, src, size)
+		},
+	}
+}
+
+// preferThumbnail returns the thumbnail of original if it exists.
+// Otherwise it schedules the thumbnail to be created and returns original in the meantime.
+func (f *Fetcher) preferThumbnail(key, original string) string {
+	size := f.getIconSize()
+	if size <= 0 {
+		return original
+	}
+
+	if p, ok := f.cache.LookupKey(thumbnailKey(key, size)); ok {
+		return p
+	}
+
+	f.schedule(f.thumbnailJob(key, original, size))
+	return original
+}
+
+func (f *Fetcher) get(j job) string {
+	// The original might have been evicted while its thumbnail is still cached
+	if size := f.getIconSize(); size > 0 {
+		if p, ok := f.cache.LookupKey(thumbnailKey(j.key, size)); ok {
+			return p
+		}
+	}
+
+	if p, ok := f.cache.LookupKey(j.key); ok {
+		return f.preferThumbnail(j.key, p)
+	}
+
+	f.schedule(j)
+	return ""
+}
+
+func (f *Fetcher) wait(j job) string {
+	if p, ok := f.cache.LookupKey(j.key); ok {
+		return p
+	}
+
+	done := f.schedule(j)
+	if done == nil {
+		return ""
+	}
+
+	select {
+	case <-done:
+	case <-time.After(f.timeout):
+		return ""
+	}
+
+	p, _ := f.cache.LookupKey(j.key)
+	return p
+}
+
+func (f *Fetcher) getLocal(url string, useThumbnail bool) string {
+	key := Key(url)
+
+	f.mu.Lock()
+	failedAt, hasFailed := f.failed[key]
+	f.mu.Unlock()
+
+	if hasFailed && time.Since(failedAt) < retryFailedAfter {
+		return ""
+	}
+
+	p, err := f.resolveLocal(url)
+	if err != nil {
+		log.Printf("art.Fetcher: Could not resolve %s: %s\n", truncateURL(url), err)
+
+		f.mu.Lock()
+		f.failed[key] = time.Now()
+		f.mu.Unlock()
+		return ""
+	}
+
+	if useThumbnail {
+		return f.preferThumbnail(key, p)
+	}
+
+	return p
+}
+
+// truncateURL keeps data uris from flooding the log
+func truncateURL(url string) string {
+	if len(url) > 64 {
+		return url[:64] + "..."
+	}
+
+	return url
+}
+
+func (f *Fetcher) schedule(j job) chan struct{} {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if done, ok := f.pending[j.key]; ok {
+		return done
+	}
+
+	if failedAt, ok := f.failed[j.key]; ok && time.Since(failedAt) < retryFailedAfter {
+		return nil
+	}
+
+	j.done = make(chan struct{})
+	select {
+	case f.jobs <- j:
+		f.pending[j.key] = j.done
+		return j.done
+	default:
+		log.Printf("art.Fetcher: Could not schedule %s: %s\n", j.name, ErrQueueFull)
+		return nil
+	}
+}
+
+func (f *Fetcher) work() {
+	for j := range f.jobs {
+		err := f.run(j)
+
+		f.mu.Lock()
+		delete(f.pending, j.key)
+		if err != nil {
+			f.failed[j.key] = time.Now()
+		} else {
+			delete(f.failed, j.key)
+		}
+		f.mu.Unlock()
+
+		close(j.done)
+
+		if err != nil {
+			log.Printf("art.Fetcher: Could not fetch %s: %s\n", j.name, err)
+			continue
+		}
+
+		if f.onReady != nil {
+			f.onReady()
+		}
+	}
+}
+
+func (f *Fetcher) run(j job) error {
+	ctx, cancel := context.WithTimeout(context.Background(), f.timeout)
+	defer cancel()
+
+	return j.run(ctx)
+}
+
+func (f *Fetcher) storeOriginal(key, name string, r io.Reader) error {
+	p, err := f.cache.StoreKey(key, r)
+	if err != nil {
+		return err
+	}
+
+	// The original is still usable when the thumbnail can't be created
+	if size := f.getIconSize(); size > 0 {
+		if err := f.storeThumbnail(key, p, size); err != nil {
+			log.Printf("art.Fetcher: Could not create thumbnail for %s: %s\n", name, err)
+
+			f.mu.Lock()
+			f.failed[thumbnailKey(key, size)] = time.Now()
+			f.mu.Unlock()
+		}
+	}
+
+	return nil
+}
+
+func (f *Fetcher) storeThumbnail(key, src string, size int) error {
+	file, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	thumb, err := Thumbnail(file, size)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.cache.StoreKey(thumbnailKey(key, size), bytes.NewReader(thumb)); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (f *Fetcher) fetch(ctx context.Context, url string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("art.fetch: %w", err)
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("art.fetch: %w", err)
+	}
+
+	// Do we need other 2xx codes here?
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("art.fetch: unexpected status %s", resp.Status)
+	}
+
+	return resp.Body, nil
+}
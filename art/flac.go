@@ -0,0 +1,177 @@
+package art
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	flacBlockTypeVorbisComment = 4
+	flacBlockTypePicture       = 6
+)
+
+// readFLACPictures reads PICTURE blocks and pictures stored in the VORBIS_COMMENT block
+func readFLACPictures(r io.ReaderAt, offset int64) ([]picture, error) {
+	magic := make([]byte, 4)
+	if _, err := r.ReadAt(magic, offset); err != nil {
+		return nil, fmt.Errorf("flac: %w", err)
+	}
+	if !bytes.Equal(magic, []byte("fLaC")) {
+		return nil, fmt.Errorf("flac: missing header")
+	}
+	offset += 4
+
+	var pictures []picture
+	header := make([]byte, 4)
+	for {
+		if _, err := r.ReadAt(header, offset); err != nil {
+			return pictures, fmt.Errorf("flac: %w", err)
+		}
+		offset += 4
+
+		isLast := header[0]&0x80 != 0
+		blockType := header[0] & 0x7f
+		size := int64(header[1])<<16 | int64(header[2])<<8 | int64(header[3])
+
+		if blockType == flacBlockTypePicture || blockType == flacBlockTypeVorbisComment {
+			block := make([]byte, size)
+			if _, err := r.ReadAt(block, offset); err != nil {
+				return pictures, fmt.Errorf("flac: %w", err)
+			}
+
+			if blockType == flacBlockTypePicture {
+				if pic, err := parsePictureBlock(block); err == nil {
+					pictures = append(pictures, pic)
+				}
+			} else {
+				pictures = append(pictures, readVorbisCommentPictures(block)...)
+			}
+		}
+
+		offset += size
+		if isLast {
+			return pictures, nil
+		}
+	}
+}
+
+// parsePictureBlock parses a FLAC METADATA_BLOCK_PICTURE.
+// All integers are big endian.
+func parsePictureBlock(b []byte) (picture, error) {
+	var pic picture
+
+	readUint32 := func() (uint32, bool) {
+		if len(b) < 4 {
+			return 0, false
+		}
+		v := binary.BigEndian.Uint32(b)
+		b = b[4:]
+		return v, true
+	}
+	readBytes := func() ([]byte, bool) {
+		n, ok := readUint32()
+		if !ok || uint64(n) > uint64(len(b)) {
+			return nil, false
+		}
+		v := b[:n]
+		b = b[n:]
+		return v, true
+	}
+
+	kind, ok := readUint32()
+	if !ok {
+		return pic, fmt.Errorf("flac: truncated picture")
+	}
+	mime, ok := readBytes()
+	if !ok {
+		return pic, fmt.Errorf("flac: truncated picture")
+	}
+	if _, ok := readBytes(); !ok {
+		return pic, fmt.Errorf("flac: truncated picture")
+	}
+
+	// Width, height, depth and number of colors
+	if len(b) < 16 {
+		return pic, fmt.Errorf("flac: truncated picture")
+	}
+	b = b[16:]
+
+	data, ok := readBytes()
+	if !ok {
+		return pic, fmt.Errorf("flac: truncated picture")
+	}
+
+	pic.kind = kind
+	pic.mime = string(mime)
+	pic.data = data
+
+	return pic, nil
+}
+
+// readVorbisCommentPictures reads METADATA_BLOCK_PICTURE and the legacy COVERART comments.
+// All integers are little endian.
+func readVorbisCommentPictures(b []byte) []picture {
+	var pictures []picture
+
+	readBytes := func() ([]byte, bool) {
+		if len(b) < 4 {
+			return nil, false
+		}
+		n := binary.LittleEndian.Uint32(b)
+		b = b[4:]
+		if uint64(n) > uint64(len(b)) {
+			return nil, false
+		}
+		v := b[:n]
+		b = b[n:]
+		return v, true
+	}
+
+	// Vendor string
+	if _, ok := readBytes(); !ok {
+		return nil
+	}
+
+	if len(b) < 4 {
+		return nil
+	}
+	count := binary.LittleEndian.Uint32(b)
+	b = b[4:]
+
+	for i := uint32(0); i < count; i++ {
+		comment, ok := readBytes()
+		if !ok {
+			break
+		}
+
+		eq := bytes.IndexByte(comment, '=')
+		if eq < 0 {
+			continue
+		}
+
+		key := strings.ToUpper(string(comment[:eq]))
+		if key != "METADATA_BLOCK_PICTURE" && key != "COVERART" {
+			continue
+		}
+
+		data, err := base64.StdEncoding.DecodeString(string(comment[eq+1:]))
+		if err != nil {
+			continue
+		}
+
+		if key == "COVERART" {
+			pictures = append(pictures, picture{kind: pictureTypeFrontCover, data: data})
+			continue
+		}
+
+		if pic, err := parsePictureBlock(data); err == nil {
+			pictures = append(pictures, pic)
+		}
+	}
+
+	return pictures
+}
@@ -0,0 +1,138 @@
+package art
+
+import (
+	"bytes"
+	"encoding/base64"
+	"testing"
+)
+
+func flacStream(blocks ...[]byte) []byte {
+	return concat([]byte("fLaC"), concat(blocks...))
+}
+
+func flacBlock(blockType byte, isLast bool, body []byte) []byte {
+	if isLast {
+		blockType |= 0x80
+	}
+
+	return concat([]byte{blockType, byte(len(body) >> 16), byte(len(body) >> 8), byte(len(body))}, body)
+}
+
+// pictureBlock builds a METADATA_BLOCK_PICTURE
+func pictureBlock(p picture) []byte {
+	description := "description"
+	return concat(
+		be32(int(p.kind)),
+		be32(len(p.mime)), []byte(p.mime),
+		be32(len(description)), []byte(description),
+		// Width, height, depth and number of colors
+		be32(600), be32(600), be32(24), be32(0),
+		be32(len(p.data)), p.data,
+	)
+}
+
+func base64Picture(p picture) string {
+	return base64.StdEncoding.EncodeToString(pictureBlock(p))
+}
+
+// vorbisComment builds a little endian comment block, as used by FLAC, Vorbis and Opus
+func vorbisComment(comments ...string) []byte {
+	vendor := "test"
+	b := concat(le32(len(vendor)), []byte(vendor), le32(len(comments)))
+	for _, c := range comments {
+		b = concat(b, le32(len(c)), []byte(c))
+	}
+
+	return b
+}
+
+func TestReadFLACPictures(t *testing.T) {
+	cover := picture{kind: pictureTypeFrontCover, mime: "image/jpeg", data: testCover}
+	back := picture{kind: 4, mime: "image/png", data: []byte("back")}
+	streamInfo := flacBlock(0, false, make([]byte, 34))
+
+	corruptPicture := pictureBlock(cover)
+	copy(corruptPicture[4:8], be32(1<<20))
+
+	tests := []struct {
+		name    string
+		data    []byte
+		want    []picture
+		wantErr bool
+	}{
+		{
+			name: "picture block",
+			data: flacStream(streamInfo, flacBlock(flacBlockTypePicture, true, pictureBlock(cover))),
+			want: []picture{cover},
+		},
+		{
+			name: "several pictures",
+			data: flacStream(streamInfo, flacBlock(flacBlockTypePicture, false, pictureBlock(back)), flacBlock(flacBlockTypePicture, true, pictureBlock(cover))),
+			want: []picture{back, cover},
+		},
+		{
+			name: "metadata block picture comment",
+			data: flacStream(streamInfo, flacBlock(flacBlockTypeVorbisComment, true, vorbisComment("TITLE=title", "metadata_block_picture="+base64Picture(cover)))),
+			want: []picture{cover},
+		},
+		{
+			name: "legacy coverart comment",
+			data: flacStream(flacBlock(flacBlockTypeVorbisComment, true, vorbisComment("COVERART="+base64.StdEncoding.EncodeToString(testCover)))),
+			want: []picture{{kind: pictureTypeFrontCover, data: testCover}},
+		},
+		{
+			name: "no pictures",
+			data: flacStream(streamInfo, flacBlock(flacBlockTypeVorbisComment, true, vorbisComment("TITLE=title"))),
+		},
+		{
+			name: "invalid base64 comment is skipped",
+			data: flacStream(flacBlock(flacBlockTypeVorbisComment, true, vorbisComment("METADATA_BLOCK_PICTURE=!!!", "METADATA_BLOCK_PICTURE="+base64Picture(cover)))),
+			want: []picture{cover},
+		},
+		{
+			name: "comment count larger than the block",
+			data: flacStream(flacBlock(flacBlockTypeVorbisComment, true, concat(le32(4), []byte("test"), le32(1000)))),
+		},
+		{
+			name: "corrupt picture block is skipped",
+			data: flacStream(flacBlock(flacBlockTypePicture, false, corruptPicture), flacBlock(flacBlockTypePicture, true, pictureBlock(back))),
+			want: []picture{back},
+		},
+		{
+			name: "truncated picture block",
+			data: flacStream(flacBlock(flacBlockTypePicture, true, pictureBlock(cover)[:20])),
+		},
+		{
+			name:    "block larger than the file",
+			data:    flacStream(streamInfo, flacBlock(flacBlockTypePicture, true, pictureBlock(cover)))[:60],
+			wantErr: true,
+		},
+		{
+			name:    "ends without a last block",
+			data:    flacStream(flacBlock(flacBlockTypePicture, false, pictureBlock(cover))),
+			want:    []picture{cover},
+			wantErr: true,
+		},
+		{
+			name:    "not flac",
+			data:    []byte("OggS\x00\x02\x00\x00"),
+			wantErr: true,
+		},
+		{
+			name:    "empty",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readFLACPictures(bytes.NewReader(tt.data), 0)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("readFLACPictures() error = %v, want error %v", err, tt.wantErr)
+			}
+			if !equalPictures(got, tt.want) {
+				t.Errorf("readFLACPictures() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
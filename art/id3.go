@@ -0,0 +1,252 @@
+package art
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+const id3HeaderSize = 10
+
+const (
+	id3FlagUnsynchronisation = 0x80
+	id3FlagExtendedHeader    = 0x40
+	id3FlagFooter            = 0x10
+)
+
+type id3Header struct {
+	version byte
+	flags   byte
+	size    int64
+}
+
+func readID3Header(r io.ReaderAt, offset int64) (id3Header, error) {
+	b := make([]byte, id3HeaderSize)
+	if _, err := r.ReadAt(b, offset); err != nil {
+		return id3Header{}, err
+	}
+
+	if !bytes.Equal(b[:3], []byte("ID3")) {
+		return id3Header{}, fmt.Errorf("id3: missing header")
+	}
+
+	return id3Header{
+		version: b[3],
+		flags:   b[5],
+		size:    int64(synchsafe(b[6:10])),
+	}, nil
+}
+
+// synchsafe decodes integers where the most significant bit of every byte is zeroed
+func synchsafe(b []byte) uint32 {
+	var n uint32
+	for _, c := range b {
+		n = n<<7 | uint32(c&0x7f)
+	}
+
+	return n
+}
+
+func removeUnsynchronisation(b []byte) []byte {
+	return bytes.ReplaceAll(b, []byte{0xff, 0x00}, []byte{0xff})
+}
+
+// flacAfterID3 returns the offset of a FLAC stream following an ID3 tag
+func flacAfterID3(r io.ReaderAt) (int64, bool) {
+	h, err := readID3Header(r, 0)
+	if err != nil {
+		return 0, false
+	}
+
+	offset := id3HeaderSize + h.size
+	if h.flags&id3FlagFooter != 0 {
+		offset += id3HeaderSize
+	}
+
+	magic := make([]byte, 4)
+	if _, err := r.ReadAt(magic, offset); err != nil || !bytes.Equal(magic, []byte("fLaC")) {
+		return 0, false
+	}
+
+	return offset, true
+}
+
+// readID3Pictures reads APIC (v2.3, v2.4) and PIC (v2.2) frames
+func readID3Pictures(r io.ReaderAt) ([]picture, error) {
+	h, err := readID3Header(r, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	if h.size > 2*maxArtSize {
+		return nil, ErrTooLarge
+	}
+
+	// A cut off file is parsed as far as it goes, without the unread zeros
+	tag := make([]byte, h.size)
+	n, err := r.ReadAt(tag, id3HeaderSize)
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("id3: %w", err)
+	}
+	tag = tag[:n]
+
+	// v2.4 unsynchronises frame by frame instead
+	if h.flags&id3FlagUnsynchronisation != 0 && h.version < 4 {
+		tag = removeUnsynchronisation(tag)
+	}
+
+	if h.flags&id3FlagExtendedHeader != 0 && h.version >= 3 {
+		if len(tag) < 4 {
+			return nil, fmt.Errorf("id3: truncated extended header")
+		}
+
+		var extSize int
+		if h.version == 3 {
+			extSize = int(binary.BigEndian.Uint32(tag[:4])) + 4
+		} else {
+			extSize = int(synchsafe(tag[:4]))
+		}
+		if extSize > len(tag) {
+			return nil, fmt.Errorf("id3: truncated extended header")
+		}
+		tag = tag[extSize:]
+	}
+
+	var pictures []picture
+	for len(tag) > 0 {
+		id, body, rest, ok := nextID3Frame(h.version, tag)
+		if !ok {
+			break
+		}
+		tag = rest
+
+		switch id {
+		case "APIC":
+			if pic, err := parseAPIC(body); err == nil {
+				pictures = append(pictures, pic)
+			}
+		case "PIC":
+			if pic, err := parsePIC(body); err == nil {
+				pictures = append(pictures, pic)
+			}
+		}
+	}
+
+	return pictures, nil
+}
+
+func nextID3Frame(version byte, tag []byte) (id string, body []byte, rest []byte, ok bool) {
+	if version == 2 {
+		if len(tag) < 6 || tag[0] == 0 {
+			return "", nil, nil, false
+		}
+
+		size := int(tag[3])<<16 | int(tag[4])<<8 | int(tag[5])
+		if 6+size > len(tag) {
+			return "", nil, nil, false
+		}
+
+		return string(tag[:3]), tag[6 : 6+size], tag[6+size:], true
+	}
+
+	if len(tag) < 10 || tag[0] == 0 {
+		return "", nil, nil, false
+	}
+
+	var size int
+	if version >= 4 {
+		size = int(synchsafe(tag[4:8]))
+	} else {
+		size = int(binary.BigEndian.Uint32(tag[4:8]))
+	}
+	if size < 0 || 10+size > len(tag) {
+		return "", nil, nil, false
+	}
+
+	id = string(tag[:4])
+	formatFlags := tag[9]
+	body = tag[10 : 10+size]
+	rest = tag[10+size:]
+
+	if version >= 4 {
+		// Compressed and encrypted frames aren't supported
+		if formatFlags&0x0c != 0 {
+			return id, nil, rest, true
+		}
+		if formatFlags&0x01 != 0 && len(body) >= 4 {
+			body = body[4:]
+		}
+		if formatFlags&0x02 != 0 {
+			body = removeUnsynchronisation(body)
+		}
+	} else {
+		if formatFlags&0xc0 != 0 {
+			return id, nil, rest, true
+		}
+		if formatFlags&0x20 != 0 && len(body) >= 1 {
+			body = body[1:]
+		}
+	}
+
+	return id, body, rest, true
+}
+
+// skipID3String skips a string terminated according to the text encoding
+func skipID3String(encoding byte, b []byte) ([]byte, bool) {
+	if encoding == 1 || encoding == 2 {
+		for i := 0; i+1 < len(b); i += 2 {
+			if b[i] == 0 && b[i+1] == 0 {
+				return b[i+2:], true
+			}
+		}
+		return nil, false
+	}
+
+	i := bytes.IndexByte(b, 0)
+	if i < 0 {
+		return nil, false
+	}
+
+	return b[i+1:], true
+}
+
+func parseAPIC(b []byte) (picture, error) {
+	if len(b) < 2 {
+		return picture{}, fmt.Errorf("id3: truncated APIC")
+	}
+
+	encoding := b[0]
+	b = b[1:]
+
+	i := bytes.IndexByte(b, 0)
+	if i < 0 || i+2 > len(b) {
+		return picture{}, fmt.Errorf("id3: truncated APIC")
+	}
+	mime := string(b[:i])
+	kind := b[i+1]
+
+	data, ok := skipID3String(encoding, b[i+2:])
+	if !ok {
+		return picture{}, fmt.Errorf("id3: truncated APIC")
+	}
+
+	return picture{kind: uint32(kind), mime: mime, data: data}, nil
+}
+
+func parsePIC(b []byte) (picture, error) {
+	if len(b) < 5 {
+		return picture{}, fmt.Errorf("id3: truncated PIC")
+	}
+
+	encoding := b[0]
+	format := string(b[1:4])
+	kind := b[4]
+
+	data, ok := skipID3String(encoding, b[5:])
+	if !ok {
+		return picture{}, fmt.Errorf("id3: truncated PIC")
+	}
+
+	return picture{kind: uint32(kind), mime: "image/" + format, data: data}, nil
+}
@@ -0,0 +1,185 @@
+package art
+
+import (
+	"bytes"
+	"testing"
+)
+
+func synchsafeBytes(n int) []byte {
+	return []byte{byte(n >> 21 & 0x7f), byte(n >> 14 & 0x7f), byte(n >> 7 & 0x7f), byte(n & 0x7f)}
+}
+
+// unsynchronise inserts a zero after every 0xff, which is what readers remove
+func unsynchronise(b []byte) []byte {
+	return bytes.ReplaceAll(b, []byte{0xff}, []byte{0xff, 0x00})
+}
+
+func id3Tag(version, flags byte, frames ...[]byte) []byte {
+	body := concat(frames...)
+	return concat([]byte("ID3"), []byte{version, 0, flags}, synchsafeBytes(len(body)), body)
+}
+
+// id3Frame builds a v2.3 or v2.4 frame with the given format flags
+func id3Frame(version byte, id string, formatFlags byte, body []byte) []byte {
+	size := be32(len(body))
+	if version >= 4 {
+		size = synchsafeBytes(len(body))
+	}
+
+	return concat([]byte(id), size, []byte{0, formatFlags}, body)
+}
+
+func id3v22Frame(id string, body []byte) []byte {
+	return concat([]byte(id), []byte{byte(len(body) >> 16), byte(len(body) >> 8), byte(len(body))}, body)
+}
+
+func apicBody(p picture) []byte {
+	return concat([]byte{0}, []byte(p.mime), []byte{0, byte(p.kind)}, []byte("description\x00"), p.data)
+}
+
+func TestReadID3Pictures(t *testing.T) {
+	cover := picture{kind: pictureTypeFrontCover, mime: "image/jpeg", data: testCover}
+	back := picture{kind: 4, mime: "image/png", data: []byte("back")}
+	title := []byte("\x00A title")
+
+	// UTF-16 descriptions end with two zero bytes
+	utf16APIC := concat([]byte{1}, []byte("image/jpeg\x00"), []byte{3}, []byte("\xff\xfeA\x00\x00\x00"), testCover)
+
+	// v2.3 frame sizes are counted before the whole tag is unsynchronised
+	unsyncFrame := id3Frame(3, "APIC", 0, apicBody(cover))
+
+	// v2.4 unsynchronises frame by frame, after the data length indicator
+	apic := apicBody(cover)
+	unsyncV24 := id3Frame(4, "APIC", 0x02|0x01, concat(synchsafeBytes(len(apic)), unsynchronise(apic)))
+
+	extended := concat(be32(6), []byte{0, 0, 0, 0, 0, 0})
+
+	tests := []struct {
+		name    string
+		data    []byte
+		want    []picture
+		wantErr bool
+	}{
+		{
+			name: "v2.3",
+			data: id3Tag(3, 0, id3Frame(3, "TIT2", 0, title), id3Frame(3, "APIC", 0, apicBody(cover))),
+			want: []picture{cover},
+		},
+		{
+			name: "v2.4",
+			data: id3Tag(4, 0, id3Frame(4, "APIC", 0, apicBody(cover)), id3Frame(4, "APIC", 0, apicBody(back))),
+			want: []picture{cover, back},
+		},
+		{
+			name: "v2.2",
+			data: id3Tag(2, 0, id3v22Frame("TT2", title), id3v22Frame("PIC", concat([]byte{0}, []byte("JPG"), []byte{3, 0}, testCover))),
+			want: []picture{{kind: pictureTypeFrontCover, mime: "image/JPG", data: testCover}},
+		},
+		{
+			name: "utf-16 description",
+			data: id3Tag(3, 0, id3Frame(3, "APIC", 0, utf16APIC)),
+			want: []picture{cover},
+		},
+		{
+			name: "v2.3 unsynchronisation",
+			data: concat([]byte("ID3"), []byte{3, 0, id3FlagUnsynchronisation}, synchsafeBytes(len(unsynchronise(unsyncFrame))), unsynchronise(unsyncFrame)),
+			want: []picture{cover},
+		},
+		{
+			name: "v2.4 frame unsynchronisation",
+			data: id3Tag(4, 0, unsyncV24),
+			want: []picture{cover},
+		},
+		{
+			name: "v2.3 extended header",
+			data: id3Tag(3, id3FlagExtendedHeader, extended, id3Frame(3, "APIC", 0, apicBody(cover))),
+			want: []picture{cover},
+		},
+		{
+			name: "padding after the frames",
+			data: id3Tag(3, 0, id3Frame(3, "APIC", 0, apicBody(cover)), make([]byte, 64)),
+			want: []picture{cover},
+		},
+		{
+			name: "compressed frames are skipped",
+			data: id3Tag(4, 0, id3Frame(4, "APIC", 0x08, apicBody(cover)), id3Frame(4, "APIC", 0, apicBody(back))),
+			want: []picture{back},
+		},
+		{
+			name: "no pictures",
+			data: id3Tag(3, 0, id3Frame(3, "TIT2", 0, title)),
+		},
+		{
+			name: "APIC without a description end",
+			data: id3Tag(3, 0, id3Frame(3, "APIC", 0, []byte("\x00image/jpeg\x00\x03description"))),
+		},
+		{
+			name: "APIC without a mime type end",
+			data: id3Tag(3, 0, id3Frame(3, "APIC", 0, []byte("\x00image/jpeg"))),
+		},
+		{
+			name: "frame larger than the tag",
+			data: id3Tag(3, 0, concat([]byte("APIC"), be32(1<<30), []byte{0, 0}, apicBody(cover))),
+		},
+		{
+			name: "tag cut off in a frame",
+			data: id3Tag(3, 0, id3Frame(3, "APIC", 0, apicBody(cover)))[:40],
+		},
+		{
+			name:    "truncated extended header",
+			data:    id3Tag(3, id3FlagExtendedHeader, be32(100)),
+			wantErr: true,
+		},
+		{
+			name:    "truncated header",
+			data:    []byte("ID3\x03\x00"),
+			wantErr: true,
+		},
+		{
+			name:    "not id3",
+			data:    []byte("fLaC\x00\x00\x00\x00\x00\x00\x00\x00"),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readID3Pictures(bytes.NewReader(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("readID3Pictures() error = %v, want error %v", err, tt.wantErr)
+			}
+			if !equalPictures(got, tt.want) {
+				t.Errorf("readID3Pictures() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFLACAfterID3(t *testing.T) {
+	tag := id3Tag(4, 0, id3Frame(4, "TIT2", 0, []byte("\x00title")))
+	flac := flacStream(flacBlock(flacBlockTypePicture, true, pictureBlock(picture{kind: pictureTypeFrontCover, data: testCover})))
+
+	withFooter := id3Tag(4, id3FlagFooter, id3Frame(4, "TIT2", 0, []byte("\x00title")))
+	footer := append([]byte("3DI"), withFooter[3:id3HeaderSize]...)
+
+	tests := []struct {
+		name   string
+		data   []byte
+		offset int64
+		ok     bool
+	}{
+		{"flac", concat(tag, flac), int64(len(tag)), true},
+		{"footer", concat(withFooter, footer, flac), int64(len(withFooter) + len(footer)), true},
+		{"mp3", concat(tag, []byte("\xff\xfb\x90\x00")), 0, false},
+		{"cut off", tag, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			offset, ok := flacAfterID3(bytes.NewReader(tt.data))
+			if ok != tt.ok || offset != tt.offset {
+				t.Errorf("flacAfterID3() = %d, %v, want %d, %v", offset, ok, tt.offset, tt.ok)
+			}
+		})
+	}
+}
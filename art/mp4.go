@@ -0,0 +1,105 @@
+package art
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+const mp4BoxHeaderSize = 8
+
+// Type indicators of the data box
+const (
+	mp4DataTypeJPEG = 13
+	mp4DataTypePNG  = 14
+	mp4DataTypeBMP  = 27
+)
+
+// findMP4Box returns the content range of the first box of the given type in [start, end)
+func findMP4Box(r io.ReaderAt, start, end int64, boxType string) (int64, int64, error) {
+	header := make([]byte, 16)
+	for offset := start; offset+mp4BoxHeaderSize <= end; {
+		if _, err := r.ReadAt(header[:mp4BoxHeaderSize], offset); err != nil {
+			return 0, 0, fmt.Errorf("mp4: %w", err)
+		}
+
+		size := int64(binary.BigEndian.Uint32(header[:4]))
+		headerSize := int64(mp4BoxHeaderSize)
+
+		switch size {
+		case 0:
+			// The box extends to the end of its parent
+			size = end - offset
+		case 1:
+			if _, err := r.ReadAt(header[8:16], offset+8); err != nil {
+				return 0, 0, fmt.Errorf("mp4: %w", err)
+			}
+			size = int64(binary.BigEndian.Uint64(header[8:16]))
+			headerSize += 8
+		}
+
+		if size < headerSize || offset+size > end {
+			return 0, 0, fmt.Errorf("mp4: invalid box size")
+		}
+
+		if string(header[4:8]) == boxType {
+			return offset + headerSize, offset + size, nil
+		}
+
+		offset += size
+	}
+
+	return 0, 0, ErrNoPicture
+}
+
+// readMP4Pictures reads moov.udta.meta.ilst.covr
+func readMP4Pictures(r io.ReaderAt, size int64) ([]picture, error) {
+	start, end := int64(0), size
+
+	for _, boxType := range []string{"moov", "udta", "meta", "ilst", "covr"} {
+		var err error
+		start, end, err = findMP4Box(r, start, end, boxType)
+		if err != nil {
+			return nil, err
+		}
+
+		// meta is a full box with version and flags before its children
+		if boxType == "meta" {
+			start += 4
+		}
+	}
+
+	var pictures []picture
+	for start < end {
+		dataStart, dataEnd, err := findMP4Box(r, start, end, "data")
+		if err != nil {
+			break
+		}
+		start = dataEnd
+
+		// Type indicator and locale
+		if dataEnd-dataStart < 8 || dataEnd-dataStart > maxArtSize {
+			continue
+		}
+
+		b := make([]byte, dataEnd-dataStart)
+		if _, err := r.ReadAt(b, dataStart); err != nil {
+			return pictures, fmt.Errorf("mp4: %w", err)
+		}
+
+		var mime string
+		switch binary.BigEndian.Uint32(b[:4]) & 0xffffff {
+		case mp4DataTypeJPEG:
+			mime = "image/jpeg"
+		case mp4DataTypePNG:
+			mime = "image/png"
+		case mp4DataTypeBMP:
+			mime = "image/bmp"
+		}
+
+		// MP4 doesn't have picture types so every cover is treated as the front cover
+		pictures = append(pictures, picture{kind: pictureTypeFrontCover, mime: mime, data: b[8:]})
+	}
+
+	return pictures, nil
+}
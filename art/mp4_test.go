@@ -0,0 +1,83 @@
+package art
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func mp4Box(boxType string, children ...[]byte) []byte {
+	body := concat(children...)
+	return concat(be32(mp4BoxHeaderSize+len(body)), []byte(boxType), body)
+}
+
+// mp4LargeBox uses a 64 bit size
+func mp4LargeBox(boxType string, children ...[]byte) []byte {
+	body := concat(children...)
+	size := make([]byte, 8)
+	binary.BigEndian.PutUint64(size, uint64(16+len(body)))
+
+	return concat(be32(1), []byte(boxType), size, body)
+}
+
+func mp4Data(dataType int, data []byte) []byte {
+	return mp4Box("data", be32(dataType), be32(0), data)
+}
+
+func mp4File(covers ...[]byte) []byte {
+	return concat(mp4Box("ftyp", []byte("M4A \x00\x00\x00\x00")), mp4Moov(mp4Box("covr", covers...)))
+}
+
+func mp4Moov(ilstChildren ...[]byte) []byte {
+	hdlr := mp4Box("hdlr", make([]byte, 25))
+	meta := mp4Box("meta", []byte{0, 0, 0, 0}, hdlr, mp4Box("ilst", ilstChildren...))
+
+	return mp4Box("moov", mp4Box("mvhd", make([]byte, 100)), mp4Box("udta", meta))
+}
+
+func TestReadMP4Pictures(t *testing.T) {
+	jpeg := picture{kind: pictureTypeFrontCover, mime: "image/jpeg", data: testCover}
+	png := picture{kind: pictureTypeFrontCover, mime: "image/png", data: []byte("\x89PNG")}
+	title := mp4Box("\xa9nam", mp4Data(1, []byte("title")))
+
+	hdlr := mp4Box("hdlr", make([]byte, 25))
+	largeMoov := mp4LargeBox("moov", mp4Box("udta", mp4Box("meta", []byte{0, 0, 0, 0}, hdlr, mp4Box("ilst", mp4Box("covr", mp4Data(mp4DataTypeJPEG, testCover))))))
+
+	// A size of 0 extends the box to the end of the file
+	openMoov := mp4Moov(mp4Box("covr", mp4Data(mp4DataTypePNG, png.data)))
+	copy(openMoov[:4], be32(0))
+
+	badSize := mp4File(mp4Data(mp4DataTypeJPEG, testCover))
+	moovAt := len(mp4Box("ftyp", []byte("M4A \x00\x00\x00\x00")))
+	copy(badSize[moovAt:moovAt+4], be32(4))
+
+	tests := []struct {
+		name    string
+		data    []byte
+		want    []picture
+		wantErr error
+	}{
+		{"jpeg", mp4File(mp4Data(mp4DataTypeJPEG, testCover)), []picture{jpeg}, nil},
+		{"several covers", mp4File(mp4Data(mp4DataTypePNG, png.data), mp4Data(mp4DataTypeJPEG, testCover)), []picture{png, jpeg}, nil},
+		{"other tags first", concat(mp4Box("ftyp"), mp4Moov(title, mp4Box("covr", mp4Data(mp4DataTypeJPEG, testCover)))), []picture{jpeg}, nil},
+		{"64 bit size", concat(mp4Box("ftyp"), largeMoov), []picture{jpeg}, nil},
+		{"size to the end", concat(mp4Box("ftyp"), openMoov), []picture{png}, nil},
+		{"short data box is skipped", mp4File(mp4Box("data", be32(mp4DataTypeJPEG)), mp4Data(mp4DataTypePNG, png.data)), []picture{png}, nil},
+		{"no covr", concat(mp4Box("ftyp"), mp4Moov(title)), nil, ErrNoPicture},
+		{"no moov", mp4Box("ftyp", []byte("M4A \x00\x00\x00\x00")), nil, ErrNoPicture},
+		{"truncated", mp4File(mp4Data(mp4DataTypeJPEG, testCover))[:80], nil, errTest},
+		{"box smaller than its header", badSize, nil, errTest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readMP4Pictures(bytes.NewReader(tt.data), int64(len(tt.data)))
+			if !matchesError(err, tt.wantErr) {
+				t.Fatalf("readMP4Pictures() error = %v, want %v", err, tt.wantErr)
+			}
+			if !equalPictures(got, tt.want) {
+				t.Errorf("readMP4Pictures() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package art
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+const oggPageHeaderSize = 27
+
+var (
+	vorbisCommentPrefix = []byte("\x03vorbis")
+	opusCommentPrefix   = []byte("OpusTags")
+)
+
+// readOggPictures reads the comment header of the first logical stream.
+// It's the second packet for both Vorbis and Opus.
+func readOggPictures(r io.ReaderAt) ([]picture, error) {
+	packet, err := readOggPacket(bufio.NewReader(io.NewSectionReader(r, 0, 1<<62)), 1)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case bytes.HasPrefix(packet, vorbisCommentPrefix):
+		return readVorbisCommentPictures(packet[len(vorbisCommentPrefix):]), nil
+	case bytes.HasPrefix(packet, opusCommentPrefix):
+		return readVorbisCommentPictures(packet[len(opusCommentPrefix):]), nil
+	default:
+		return nil, ErrNoPicture
+	}
+}
+
+// readOggPacket reassembles packet number index of the first logical stream
+func readOggPacket(r io.Reader, index int) ([]byte, error) {
+	var serial uint32
+	var packet []byte
+	current := 0
+	isFirstPage := true
+
+	header := make([]byte, oggPageHeaderSize)
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			return nil, fmt.Errorf("ogg: %w", err)
+		}
+		if !bytes.Equal(header[:4], []byte("OggS")) {
+			return nil, fmt.Errorf("ogg: lost sync")
+		}
+
+		pageSerial := binary.LittleEndian.Uint32(header[14:18])
+		if isFirstPage {
+			serial = pageSerial
+			isFirstPage = false
+		}
+
+		segments := make([]byte, header[26])
+		if _, err := io.ReadFull(r, segments); err != nil {
+			return nil, fmt.Errorf("ogg: %w", err)
+		}
+
+		var size int
+		for _, s := range segments {
+			size += int(s)
+		}
+
+		body := make([]byte, size)
+		if _, err := io.ReadFull(r, body); err != nil {
+			return nil, fmt.Errorf("ogg: %w", err)
+		}
+
+		// Pages from multiplexed streams
+		if pageSerial != serial {
+			continue
+		}
+
+		for _, s := range segments {
+			if current == index {
+				packet = append(packet, body[:s]...)
+				if len(packet) > 2*maxArtSize {
+					return nil, ErrTooLarge
+				}
+			}
+			body = body[s:]
+
+			// A segment shorter than 255 bytes ends the packet
+			if s < 255 {
+				if current == index {
+					return packet, nil
+				}
+				current++
+			}
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package art
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+// oggPages splits packets into pages of at most perPage segments.
+// The checksum isn't set, since the reader doesn't check it.
+func oggPages(serial uint32, perPage int, packets ...[]byte) [][]byte {
+	var lacing, data []byte
+	for _, p := range packets {
+		n := len(p)
+		for ; n >= 255; n -= 255 {
+			lacing = append(lacing, 255)
+		}
+		lacing = append(lacing, byte(n))
+		data = append(data, p...)
+	}
+
+	var pages [][]byte
+	for seq := 0; len(lacing) > 0; seq++ {
+		n := perPage
+		if n > len(lacing) {
+			n = len(lacing)
+		}
+		segments := lacing[:n]
+		lacing = lacing[n:]
+
+		size := 0
+		for _, s := range segments {
+			size += int(s)
+		}
+
+		header := make([]byte, oggPageHeaderSize)
+		copy(header, "OggS")
+		binary.LittleEndian.PutUint32(header[14:18], serial)
+		binary.LittleEndian.PutUint32(header[18:22], uint32(seq))
+		header[26] = byte(len(segments))
+
+		pages = append(pages, concat(header, segments, data[:size]))
+		data = data[size:]
+	}
+
+	return pages
+}
+
+func oggStream(serial uint32, perPage int, packets ...[]byte) []byte {
+	return concat(oggPages(serial, perPage, packets...)...)
+}
+
+func TestReadOggPictures(t *testing.T) {
+	cover := picture{kind: pictureTypeFrontCover, mime: "image/jpeg", data: testCover}
+	comment := vorbisComment("TITLE=title", "METADATA_BLOCK_PICTURE="+base64Picture(cover))
+
+	vorbisHead := []byte("\x01vorbis\x00\x00\x00\x00\x02\x44\xac\x00\x00")
+	vorbisTags := concat(vorbisCommentPrefix, comment)
+	opusHead := []byte("OpusHead\x01\x02\x38\x01\x80\xbb\x00\x00")
+	opusTags := concat(opusCommentPrefix, comment)
+
+	// Comments longer than 255 bytes are laced over several segments, and here several pages
+	long := concat(opusCommentPrefix, vorbisComment("DESCRIPTION="+string(bytes.Repeat([]byte("x"), 600)), "METADATA_BLOCK_PICTURE="+base64Picture(cover)))
+
+	// Another logical stream is multiplexed in after the first page
+	first := oggPages(1, 1, opusHead, opusTags)
+	other := oggPages(2, 255, []byte("\x01theora"), []byte("\x81theora"))
+	multiplexed := concat(first[0], other[0], concat(first[1:]...))
+
+	lostSync := oggStream(1, 1, opusHead, opusTags)
+	copy(lostSync[oggPageHeaderSize+1+len(opusHead):], "Bad!")
+
+	tests := []struct {
+		name    string
+		data    []byte
+		want    []picture
+		wantErr error
+	}{
+		{"vorbis", oggStream(1, 255, vorbisHead, vorbisTags), []picture{cover}, nil},
+		{"opus", oggStream(1, 255, opusHead, opusTags), []picture{cover}, nil},
+		{"packet over several pages", oggStream(1, 1, opusHead, long), []picture{cover}, nil},
+		{"multiplexed streams", multiplexed, []picture{cover}, nil},
+		{"no pictures", oggStream(1, 255, vorbisHead, concat(vorbisCommentPrefix, vorbisComment("TITLE=title"))), nil, nil},
+		{"unknown codec", oggStream(1, 255, []byte("\x80theora"), []byte("\x81theora")), nil, ErrNoPicture},
+		{"truncated comment", oggStream(1, 255, opusHead, opusTags)[:60], nil, errTest},
+		{"only the first packet", oggStream(1, 255, opusHead), nil, errTest},
+		{"lost sync", lostSync, nil, errTest},
+		{"empty", nil, nil, errTest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readOggPictures(bytes.NewReader(tt.data))
+			if !matchesError(err, tt.wantErr) {
+				t.Fatalf("readOggPictures() error = %v, want %v", err, tt.wantErr)
+			}
+			if !equalPictures(got, tt.want) {
+				t.Errorf("readOggPictures() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// errTest stands for any error in the tables
+var errTest = errors.New("any error")
+
+func matchesError(err, want error) bool {
+	if want == errTest {
+		return err != nil
+	}
+	if want == nil {
+		return err == nil
+	}
+
+	return errors.Is(err, want)
+}
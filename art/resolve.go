@@ -0,0 +1,160 @@
+package art
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var ErrUnsupportedScheme = errors.New("unsupported scheme")
+
+type scheme int
+
+const (
+	schemeUnsupported scheme = iota
+	schemeHTTP
+	schemeFile
+	schemeData
+)
+
+func schemeOf(rawURL string) scheme {
+	i := strings.Index(rawURL, ":")
+	if i < 0 {
+		return schemeUnsupported
+	}
+
+	switch strings.ToLower(rawURL[:i]) {
+	case "http", "https":
+		return schemeHTTP
+	case "file":
+		return schemeFile
+	case "data":
+		return schemeData
+	default:
+		return schemeUnsupported
+	}
+}
+
+// resolveLocal resolves art that doesn't need to go over the network
+func (f *Fetcher) resolveLocal(rawURL string) (string, error) {
+	switch schemeOf(rawURL) {
+	case schemeFile:
+		return resolveFile(rawURL)
+	case schemeData:
+		if p, ok := f.cache.Lookup(rawURL); ok {
+			return p, nil
+		}
+		return f.storeDataURI(rawURL)
+	default:
+		return "", fmt.Errorf("art.resolveLocal: %w", ErrUnsupportedScheme)
+	}
+}
+
+// resolveFile validates that a file:// url points to a readable image and returns its path
+func resolveFile(rawURL string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("art.resolveFile: %w", err)
+	}
+
+	if u.Host != "" && u.Host != "localhost" {
+		return "", fmt.Errorf("art.resolveFile: remote host %s: %w", u.Host, ErrUnsupportedScheme)
+	}
+
+	p := filepath.Clean(u.Path)
+	if !filepath.IsAbs(p) {
+		return "", fmt.Errorf("art.resolveFile: path is not absolute: %s", p)
+	}
+
+	if err := validateImageFile(p); err != nil {
+		return "", fmt.Errorf("art.resolveFile: %w", err)
+	}
+
+	return p, nil
+}
+
+func validateImageFile(p string) error {
+	info, err := os.Stat(p)
+	if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() || info.Size() == 0 {
+		return fmt.Errorf("%s is not a regular file", p)
+	}
+	if info.Size() > maxArtSize {
+		return ErrTooLarge
+	}
+
+	file, err := os.Open(p)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return err
+	}
+
+	if !strings.HasPrefix(http.DetectContentType(head[:n]), "image/") {
+		return ErrNotAnImage
+	}
+
+	return nil
+}
+
+// storeDataURI decodes a data: uri (RFC 2397) into the cache
+func (f *Fetcher) storeDataURI(rawURL string) (string, error) {
+	data, err := decodeDataURI(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("art.storeDataURI: %w", err)
+	}
+
+	p, err := f.cache.Store(rawURL, bytes.NewReader(data))
+	if err != nil {
+		return "", fmt.Errorf("art.storeDataURI: %w", err)
+	}
+
+	return p, nil
+}
+
+func decodeDataURI(rawURL string) ([]byte, error) {
+	i := strings.Index(rawURL, ",")
+	if i < 0 {
+		return nil, fmt.Errorf("data uri is missing a comma")
+	}
+
+	meta, payload := rawURL[len("data:"):i], rawURL[i+1:]
+
+	if !strings.HasSuffix(strings.ToLower(meta), ";base64") {
+		data, err := url.PathUnescape(payload)
+		if err != nil {
+			return nil, err
+		}
+		return []byte(data), nil
+	}
+
+	if base64.StdEncoding.DecodedLen(len(payload)) > maxArtSize {
+		return nil, ErrTooLarge
+	}
+
+	payload = strings.TrimRight(payload, "=")
+	data, err := base64.RawStdEncoding.DecodeString(payload)
+	if err != nil {
+		// Some players use the url safe alphabet
+		if data, err := base64.RawURLEncoding.DecodeString(payload); err == nil {
+			return data, nil
+		}
+		return nil, err
+	}
+
+	return data, nil
+}
@@ -0,0 +1,169 @@
+package art
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"
+	_ "image/jpeg"
+	"image/png"
+	"io"
+	"strconv"
+)
+
+// Decode limits so broken or hostile images can't exhaust memory
+const (
+	maxImageDimension = 4096
+	maxImagePixels    = 12_000_000
+)
+
+var ErrDecode = errors.New("could not decode image")
+
+func thumbnailKey(key string, size int) string {
+	return key + "-" + strconv.Itoa(size)
+}
+
+// Thumbnail decodes an image and scales it down to fit within size x size pixels.
+// Images that are already small enough are only re-encoded. The result is always a PNG.
+func Thumbnail(r io.Reader, size int) (thumb []byte, err error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxArtSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("art.Thumbnail: %w", err)
+	}
+	if len(data) > maxArtSize {
+		return nil, fmt.Errorf("art.Thumbnail: %w", ErrTooLarge)
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("art.Thumbnail: %w: %s", ErrDecode, err)
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 {
+		return nil, fmt.Errorf("art.Thumbnail: %w: empty image", ErrDecode)
+	}
+	if cfg.Width > maxImageDimension || cfg.Height > maxImageDimension || cfg.Width*cfg.Height > maxImagePixels {
+		return nil, fmt.Errorf("art.Thumbnail: %w: %dx%d", ErrTooLarge, cfg.Width, cfg.Height)
+	}
+
+	// The decoders aren't guaranteed to handle every malformed input gracefully
+	defer func() {
+		if r := recover(); r != nil {
+			thumb = nil
+			err = fmt.Errorf("art.Thumbnail: %w: %v", ErrDecode, r)
+		}
+	}()
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("art.Thumbnail: %w: %s", ErrDecode, err)
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, resize(img, size)); err != nil {
+		return nil, fmt.Errorf("art.Thumbnail: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// resize scales src down with a box filter while keeping the aspect ratio.
+// The pixels are read from the decoded image, so it isn't copied first.
+func resize(src image.Image, size int) image.Image {
+	bounds := src.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+
+	if w <= size && h <= size {
+		return src
+	}
+
+	dw, dh := size, size
+	if w > h {
+		dh = h * size / w
+	} else if h > w {
+		dw = w * size / h
+	}
+	if dw < 1 {
+		dw = 1
+	}
+	if dh < 1 {
+		dh = 1
+	}
+
+	at := pixelReader(src)
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		y0, y1 := y*h/dh, (y+1)*h/dh
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+
+		for x := 0; x < dw; x++ {
+			x0, x1 := x*w/dw, (x+1)*w/dw
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					c := at(bounds.Min.X+sx, bounds.Min.Y+sy)
+					r += uint64(c.R)
+					g += uint64(c.G)
+					b += uint64(c.B)
+					a += uint64(c.A)
+					n++
+				}
+			}
+
+			i := dst.PixOffset(x, y)
+			dst.Pix[i] = uint8(r / n)
+			dst.Pix[i+1] = uint8(g / n)
+			dst.Pix[i+2] = uint8(b / n)
+			dst.Pix[i+3] = uint8(a / n)
+		}
+	}
+
+	return dst
+}
+
+// pixelReader reads the premultiplied colour of a pixel.
+// The types the decoders usually return are read directly, since At allocates for every pixel.
+func pixelReader(src image.Image) func(x, y int) color.RGBA {
+	switch img := src.(type) {
+	case *image.YCbCr:
+		return func(x, y int) color.RGBA {
+			yi, ci := img.YOffset(x, y), img.COffset(x, y)
+			r, g, b := color.YCbCrToRGB(img.Y[yi], img.Cb[ci], img.Cr[ci])
+			return color.RGBA{R: r, G: g, B: b, A: 0xff}
+		}
+	case *image.RGBA:
+		return func(x, y int) color.RGBA {
+			i := img.PixOffset(x, y)
+			return color.RGBA{R: img.Pix[i], G: img.Pix[i+1], B: img.Pix[i+2], A: img.Pix[i+3]}
+		}
+	case *image.NRGBA:
+		return func(x, y int) color.RGBA {
+			i := img.PixOffset(x, y)
+			a := uint16(img.Pix[i+3])
+			return color.RGBA{
+				R: uint8(uint16(img.Pix[i]) * a / 0xff),
+				G: uint8(uint16(img.Pix[i+1]) * a / 0xff),
+				B: uint8(uint16(img.Pix[i+2]) * a / 0xff),
+				A: uint8(a),
+			}
+		}
+	case *image.Gray:
+		return func(x, y int) color.RGBA {
+			v := img.Pix[img.PixOffset(x, y)]
+			return color.RGBA{R: v, G: v, B: v, A: 0xff}
+		}
+	}
+
+	return func(x, y int) color.RGBA {
+		r, g, b, a := src.At(x, y).RGBA()
+		return color.RGBA{R: uint8(r >> 8), G: uint8(g >> 8), B: uint8(b >> 8), A: uint8(a >> 8)}
+	}
+}
@@ -0,0 +1,140 @@
+package art
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/png"
+	"testing"
+)
+
+func fillTestImage(img interface {
+	image.Image
+	Set(x, y int, c color.Color)
+}) {
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			img.Set(x, y, color.NRGBA{R: uint8(x * 7), G: uint8(y * 13), B: uint8(x + y), A: uint8(128 + x)})
+		}
+	}
+}
+
+func newTestYCbCr(r image.Rectangle) *image.YCbCr {
+	img := image.NewYCbCr(r, image.YCbCrSubsampleRatio420)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			img.Y[img.YOffset(x, y)] = uint8(x * 5)
+			img.Cb[img.COffset(x, y)] = uint8(y * 9)
+			img.Cr[img.COffset(x, y)] = uint8(x + y)
+		}
+	}
+
+	return img
+}
+
+func TestPixelReader(t *testing.T) {
+	r := image.Rect(3, 5, 23, 17)
+
+	rgba := image.NewRGBA(r)
+	fillTestImage(rgba)
+	nrgba := image.NewNRGBA(r)
+	fillTestImage(nrgba)
+	gray := image.NewGray(r)
+	fillTestImage(gray)
+	paletted := image.NewPaletted(r, palette.Plan9)
+	fillTestImage(paletted)
+
+	tests := []struct {
+		name string
+		img  image.Image
+	}{
+		{"rgba", rgba},
+		{"nrgba", nrgba},
+		{"gray", gray},
+		{"ycbcr", newTestYCbCr(r)},
+		{"paletted", paletted},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			at := pixelReader(tt.img)
+			for y := r.Min.Y; y < r.Max.Y; y++ {
+				for x := r.Min.X; x < r.Max.X; x++ {
+					got := at(x, y)
+					want := color.RGBAModel.Convert(tt.img.At(x, y)).(color.RGBA)
+					if !closeColor(got, want) {
+						t.Fatalf("pixel %d,%d = %v, want %v", x, y, got, want)
+					}
+				}
+			}
+		})
+	}
+}
+
+// closeColor allows for rounding, since the fast paths convert with 8 bits
+func closeColor(a, b color.RGBA) bool {
+	near := func(x, y uint8) bool {
+		return x-y <= 1 || y-x <= 1
+	}
+
+	return near(a.R, b.R) && near(a.G, b.G) && near(a.B, b.B) && near(a.A, b.A)
+}
+
+func encodeTestPNG(t *testing.T, w, h int) []byte {
+	t.Helper()
+
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	fillTestImage(img)
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func TestThumbnail(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   []byte
+		size   int
+		width  int
+		height int
+		err    error
+	}{
+		{"wide", encodeTestPNG(t, 300, 150), 64, 64, 32, nil},
+		{"tall", encodeTestPNG(t, 150, 300), 64, 32, 64, nil},
+		{"small", encodeTestPNG(t, 20, 10), 64, 20, 10, nil},
+		{"too wide", encodeTestPNG(t, maxImageDimension+1, 1), 64, 0, 0, ErrTooLarge},
+		{"too many pixels", encodeTestPNG(t, 4000, 3001), 64, 0, 0, ErrTooLarge},
+		{"not an image", []byte("not an image"), 64, 0, 0, ErrDecode},
+		{"truncated", encodeTestPNG(t, 300, 150)[:100], 64, 0, 0, ErrDecode},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			thumb, err := Thumbnail(bytes.NewReader(tt.data), tt.size)
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("Thumbnail() error = %v, want %v", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Thumbnail() error = %v", err)
+			}
+
+			cfg, err := png.DecodeConfig(bytes.NewReader(thumb))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.Width != tt.width || cfg.Height != tt.height {
+				t.Errorf("Thumbnail() is %dx%d, want %dx%d", cfg.Width, cfg.Height, tt.width, tt.height)
+			}
+		})
+	}
+}
@@ -0,0 +1,336 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ingentingalls/rofi"
+	"github.com/ingentingalls/rofi-media/mpris"
+)
+
+const bookmarksStateFile = "bookmarks.json"
+
+// Players take a moment to load what they're asked to open
+const bookmarkOpenTimeout = 5 * time.Second
+const bookmarkOpenPoll = 100 * time.Millisecond
+
+// bookmark is a position in a track to come back to, e.g. in an audiobook or a lecture
+type bookmark struct {
+	ID       int    `json:"id"`
+	Player   string `json:"player"`
+	Identity string `json:"identity,omitempty"`
+
+	// The track is recognised by its url, or by its id and title when it has none
+	URL     string `json:"url,omitempty"`
+	TrackID string `json:"track_id,omitempty"`
+	Title   string `json:"title,omitempty"`
+	Artist  string `json:"artist,omitempty"`
+	ArtURL  string `json:"art_url,omitempty"`
+
+	Position time.Duration `json:"position"`
+	Note     string        `json:"note,omitempty"`
+	Created  time.Time     `json:"created"`
+}
+
+type bookmarkState struct {
+	Bookmarks []bookmark `json:"bookmarks"`
+}
+
+func newBookmark(p mpris.Player, pos time.Duration) bookmark {
+	m := p.GetMetadata()
+
+	return bookmark{
+		Player:   p.Name,
+		Identity: p.Identity,
+		URL:      m.URL,
+		TrackID:  m.ID,
+		Title:    m.Title,
+		Artist:   m.Artist,
+		ArtURL:   m.ArtURL,
+		Position: pos,
+		Created:  time.Now(),
+	}
+}
+
+// isTrack reports whether m is the bookmarked track
+func (b bookmark) isTrack(m mpris.Media) bool {
+	if b.URL != "" {
+		return b.URL == m.URL
+	}
+
+	return b.TrackID != "" && b.TrackID == m.ID && b.Title == m.Title
+}
+
+func loadBookmarks() bookmarkState {
+	var s bookmarkState
+	if err := readState(statePath(bookmarksStateFile), &s); err != nil {
+		log.Printf("Could not load bookmarks: %s", err)
+	}
+
+	return s
+}
+
+// updateBookmarks loads the bookmarks, lets update change them and saves them.
+// Rofi and the command line both change the bookmarks, so the file is locked meanwhile.
+func updateBookmarks(update func(s *bookmarkState) error) error {
+	unlock, err := lockState(statePath(bookmarksStateFile))
+	if err != nil {
+		return fmt.Errorf("bookmark: %w", err)
+	}
+	defer unlock()
+
+	// A file that can't be read isn't replaced, or every bookmark in it would be lost
+	var s bookmarkState
+	if err := readState(statePath(bookmarksStateFile), &s); err != nil {
+		return fmt.Errorf("bookmark: %w", err)
+	}
+
+	if err := update(&s); err != nil {
+		return err
+	}
+
+	if err := writeState(statePath(bookmarksStateFile), s); err != nil {
+		return fmt.Errorf("bookmark: %w", err)
+	}
+
+	return nil
+}
+
+func findBookmark(id int) (bookmark, error) {
+	for _, b := range loadBookmarks().Bookmarks {
+		if b.ID == id {
+			return b, nil
+		}
+	}
+
+	return bookmark{}, fmt.Errorf("bookmark: no bookmark with id %d", id)
+}
+
+// addBookmark bookmarks the current position of a player
+func addBookmark(p mpris.Player, note string) (bookmark, error) {
+	m := p.GetMetadata()
+	if m.URL == "" && (m.ID == "" || m.Title == "") {
+		return bookmark{}, fmt.Errorf("bookmark: %s doesn't say what it's playing", p.Name)
+	}
+
+	pos, err := p.GetPosition()
+	if err != nil {
+		pos = p.Position()
+	}
+
+	b := newBookmark(p, pos)
+	b.Note = note
+
+	err = updateBookmarks(func(s *bookmarkState) error {
+		for _, existing := range s.Bookmarks {
+			if existing.ID >= b.ID {
+				b.ID = existing.ID + 1
+			}
+		}
+		if b.ID == 0 {
+			b.ID = 1
+		}
+
+		s.Bookmarks = append(s.Bookmarks, b)
+		return nil
+	})
+
+	return b, err
+}
+
+func removeBookmark(id int) error {
+	return updateBookmarks(func(s *bookmarkState) error {
+		for i, b := range s.Bookmarks {
+			if b.ID == id {
+				s.Bookmarks = append(s.Bookmarks[:i], s.Bookmarks[i+1:]...)
+				return nil
+			}
+		}
+
+		return fmt.Errorf("bookmark: no bookmark with id %d", id)
+	})
+}
+
+func noteBookmark(id int, note string) error {
+	return updateBookmarks(func(s *bookmarkState) error {
+		for i, b := range s.Bookmarks {
+			if b.ID == id {
+				s.Bookmarks[i].Note = note
+				return nil
+			}
+		}
+
+		return fmt.Errorf("bookmark: no bookmark with id %d", id)
+	})
+}
+
+// openBookmark goes back to a bookmark. The track is opened again unless the player is already on it.
+func openBookmark(players []mpris.Player, id int) error {
+	b, err := findBookmark(id)
+	if err != nil {
+		return err
+	}
+
+	p, err := findPlayerFor(players, b.Player, b.Identity)
+	if err != nil {
+		return err
+	}
+
+	if !b.isTrack(p.GetMetadata()) {
+		if b.URL == "" {
+			return fmt.Errorf("bookmark: %s isn't playing %s, and it has no url to open", p.Name, b.Title)
+		}
+		// The daemon would resume the track where it was left, racing the seek to the bookmark
+		if daemon != nil && daemon.IsRunning() {
+			if err := daemon.SkipResume(b.URL, 2*bookmarkOpenTimeout); err != nil {
+				log.Printf("Could not keep the daemon from resuming: %s", err)
+			}
+		}
+		if err := openMedia(players, p.Name, "", b.URL); err != nil {
+			return err
+		}
+		if err := waitForTrack(*p, b); err != nil {
+			return err
+		}
+	}
+
+	if err := p.SeekTo(b.Position); err != nil {
+		return fmt.Errorf("bookmark: %w", err)
+	}
+
+	if !p.IsPlaying() {
+		playPlayers([]mpris.Player{*p})
+	}
+
+	return nil
+}
+
+// waitForTrack waits for the player to load the bookmarked track, since it can't be seeked before that
+func waitForTrack(p mpris.Player, b bookmark) error {
+	deadline := time.Now().Add(bookmarkOpenTimeout)
+	for time.Now().Before(deadline) {
+		if m, err := p.FetchMetadata(); err == nil && b.isTrack(m) {
+			return nil
+		}
+		time.Sleep(bookmarkOpenPoll)
+	}
+
+	return fmt.Errorf("bookmark: %s didn't load %s", p.Name, truncate(80, b.URL))
+}
+
+func formatBookmark(b bookmark) string {
+	title := b.Title
+	if title == "" {
+		title = b.URL
+	}
+
+	return title + " @ " + formatDuration(b.Position)
+}
+
+func formatBookmarkDetails(b bookmark) string {
+	var details []string
+	if b.Note != "" {
+		details = append(details, b.Note)
+	}
+	if b.Artist != "" {
+		details = append(details, b.Artist)
+	}
+	if b.Identity != "" {
+		details = append(details, b.Identity)
+	}
+
+	return strings.Join(details, " · ")
+}
+
+func showBookmarkAction() []rofi.Option {
+	if len(loadBookmarks().Bookmarks) == 0 {
+		return nil
+	}
+
+	return []rofi.Option{{
+		Label: "Bookmarks",
+		Cmds:  []string{"bookmarks"},
+		Icon:  getConfig().Icons.Bookmark,
+	}}
+}
+
+// showBookmarks lists the bookmarks, newest first. Selecting one goes back to it, and the second command removes it
+func showBookmarks() []rofi.Option {
+	c := getConfig()
+	bookmarks := loadBookmarks().Bookmarks
+
+	var opts []rofi.Option
+	for i := len(bookmarks) - 1; i >= 0; i-- {
+		b := bookmarks[i]
+
+		icon := c.Icons.Bookmark
+		if b.ArtURL != "" {
+			if artIcon := getIconFromURL(b.ArtURL); artIcon != "" {
+				icon = artIcon
+			}
+		}
+
+		opts = append(opts, rofi.Option{
+			Label:    html.EscapeString(formatBookmark(b)),
+			Category: fmt.Sprintf("<span color=\"%s\">%s</span>", html.EscapeString(c.Colors.Category), html.EscapeString(formatBookmarkDetails(b))),
+			Icon:     icon,
+			Value:    strconv.Itoa(b.ID),
+			Cmds:     []string{"openBookmark", "removeBookmark"},
+
+			IsMultiline: true,
+			UseMarkup:   true,
+		})
+	}
+
+	opts = append(opts, rofi.Option{
+		Label: "Back",
+		Cmds:  []string{"showAll"},
+		Icon:  c.Icons.Back,
+	})
+
+	return opts
+}
+
+// runBookmark manages the bookmarks from the command line, where they can also be given a note
+func runBookmark(args []string) {
+	cmd := "list"
+	if len(args) > 0 {
+		cmd, args = args[0], args[1:]
+	}
+
+	switch cmd {
+	case "list":
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		for _, b := range loadBookmarks().Bookmarks {
+			fmt.Fprintf(w, "%d\t%s\t%s\n", b.ID, formatBookmark(b), formatBookmarkDetails(b))
+		}
+		w.Flush()
+
+	case "remove", "note":
+		if len(args) < 1 || cmd == "remove" && len(args) != 1 {
+			log.Fatalf("usage: rofi-media bookmark remove id | note id text...")
+		}
+		id, err := strconv.Atoi(args[0])
+		if err != nil {
+			log.Fatalf("bookmark: invalid id %q", args[0])
+		}
+
+		if cmd == "remove" {
+			err = removeBookmark(id)
+		} else {
+			err = noteBookmark(id, strings.Join(args[1:], " "))
+		}
+		if err != nil {
+			log.Fatalf("%s", err)
+		}
+
+	default:
+		log.Fatalf("bookmark: unknown command %q", cmd)
+	}
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+func TestUpdateBookmarksKeepsUnreadableFile(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+
+	corrupt := []byte(`{"bookmarks": [{"id": 1, "url": "file:///a.mp3"`)
+	if err := os.MkdirAll(stateDir(), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(statePath(bookmarksStateFile), corrupt, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := removeBookmark(1); err == nil {
+		t.Error("removeBookmark() succeeded on an unreadable file")
+	}
+
+	data, err := os.ReadFile(statePath(bookmarksStateFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != string(corrupt) {
+		t.Errorf("removeBookmark() replaced the unreadable file with %s", data)
+	}
+}
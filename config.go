@@ -0,0 +1,332 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/ingentingalls/rofi-media/art"
+)
+
+const configReloadInterval = 2 * time.Second
+
+type Config struct {
+	Prompt string `toml:"prompt"`
+
+	Art      ArtConfig      `toml:"art"`
+	Format   FormatConfig   `toml:"format"`
+	Colors   ColorConfig    `toml:"colors"`
+	Icons    IconConfig     `toml:"icons"`
+	Players  PlayerConfig   `toml:"players"`
+	Features FeatureConfig  `toml:"features"`
+	Sleep    SleepConfig    `toml:"sleep"`
+	Fade     FadeConfig     `toml:"fade"`
+	Alarm    AlarmConfig    `toml:"alarm"`
+	History  HistoryConfig  `toml:"history"`
+	Scrobble ScrobbleConfig `toml:"scrobble"`
+	Resume   ResumeConfig   `toml:"resume"`
+
+	Rules    []RuleConfig    `toml:"rules"`
+	Policies []PolicyConfig  `toml:"policies"`
+	Inhibit  []InhibitConfig `toml:"inhibit"`
+
+	formats *formatter
+}
+
+type ArtConfig struct {
+	Dir       string        `toml:"dir"`
+	MaxSizeMB int64         `toml:"max_size_mb"`
+	MaxAge    time.Duration `toml:"max_age"`
+
+	// Art is scaled down to fit within IconSize x IconSize pixels. 0 disables thumbnails
+	IconSize int `toml:"icon_size"`
+
+	// Colors extracts the dominant and accent colour of the art for the templates and category
+	Colors bool `toml:"colors"`
+}
+
+type FormatConfig struct {
+	Title   string `toml:"title"`
+	Message string `toml:"message"`
+}
+
+type ColorConfig struct {
+	Category string `toml:"category"`
+}
+
+type IconConfig struct {
+	Play     string `toml:"play"`
+	Pause    string `toml:"pause"`
+	Stop     string `toml:"stop"`
+	Previous string `toml:"previous"`
+	Next     string `toml:"next"`
+	Back     string `toml:"back"`
+	Sleep    string `toml:"sleep"`
+	Alarm    string `toml:"alarm"`
+	History  string `toml:"history"`
+	Stats    string `toml:"stats"`
+	Bookmark string `toml:"bookmark"`
+
+	// Fallback icons keyed by a substring of the player's bus name.
+	// Takes precedence over the icon from the player's .desktop file
+	Players map[string]string `toml:"players"`
+}
+
+type PlayerConfig struct {
+	Order  []string `toml:"order"`
+	Hidden []string `toml:"hidden"`
+}
+
+// FadeConfig ramps the volume when playing and pausing. Players without volume control aren't faded
+type FadeConfig struct {
+	Play  time.Duration `toml:"play"`
+	Pause time.Duration `toml:"pause"`
+}
+
+type HistoryConfig struct {
+	// Enabled makes the daemon record every track that was played
+	Enabled bool `toml:"enabled"`
+	// Show is how many entries the history view shows
+	Show int `toml:"show"`
+}
+
+// ScrobbleConfig submits listens to a ListenBrainz compatible server
+type ScrobbleConfig struct {
+	Enabled bool   `toml:"enabled"`
+	URL     string `toml:"url"`
+	Token   string `toml:"token"`
+}
+
+// ResumeConfig makes the daemon seek back to where long tracks were left when they're played again
+type ResumeConfig struct {
+	Enabled bool `toml:"enabled"`
+	// MinLength is how long a track has to be to be resumed
+	MinLength time.Duration `toml:"min_length"`
+}
+
+type AlarmConfig struct {
+	// Ramp is how long the volume takes to reach its level when an alarm goes off
+	Ramp time.Duration `toml:"ramp"`
+}
+
+type SleepConfig struct {
+	// Durations are offered in the sleep timer view
+	Durations []time.Duration `toml:"durations"`
+	// Fade is how long the volume fades out before the players are paused
+	Fade time.Duration `toml:"fade"`
+	// PauseAll pauses every player instead of only the one that was playing when the timer was set
+	PauseAll bool `toml:"pause_all"`
+}
+
+type FeatureConfig struct {
+	// Notifications makes the daemon show the track when it changes
+	Notifications bool `toml:"notifications"`
+
+	// ExclusivePlayback makes the daemon pause the other players when a player starts playing
+	ExclusivePlayback bool `toml:"exclusive_playback"`
+
+	// PauseOnLock makes the daemon pause the players when the screen locks or the computer goes to sleep
+	PauseOnLock bool `toml:"pause_on_lock"`
+	// ResumeOnUnlock resumes the players that were paused by PauseOnLock
+	ResumeOnUnlock bool `toml:"resume_on_unlock"`
+}
+
+func defaultConfig() Config {
+	return Config{
+		Prompt: "Players",
+		Art: ArtConfig{
+			Dir:       art.DefaultCacheDir(),
+			MaxSizeMB: 100,
+			MaxAge:    30 * 24 * time.Hour,
+			IconSize:  128,
+		},
+		Format: FormatConfig{
+			Title:   defaultTitleFormat,
+			Message: defaultMessageFormat,
+		},
+		Colors: ColorConfig{
+			Category: "#C3C3C3",
+		},
+		Icons: IconConfig{
+			Play:     "player_play",
+			Pause:    "player_pause",
+			Stop:     "player_stop",
+			Previous: "player_rew",
+			Next:     "player_fwd",
+			Back:     "back",
+			Sleep:    "clock",
+			Alarm:    "alarm-clock",
+			History:  "document-open-recent",
+			Stats:    "view-statistics",
+			Bookmark: "bookmark-new",
+		},
+		Features: FeatureConfig{
+			ExclusivePlayback: true,
+		},
+		Sleep: SleepConfig{
+			Durations: []time.Duration{15 * time.Minute, 30 * time.Minute, 45 * time.Minute, time.Hour, 90 * time.Minute},
+			Fade:      10 * time.Second,
+		},
+		History: HistoryConfig{
+			Enabled: true,
+			Show:    50,
+		},
+		Scrobble: ScrobbleConfig{
+			URL: defaultScrobbleURL,
+		},
+		Alarm: AlarmConfig{
+			Ramp: 30 * time.Second,
+		},
+		Resume: ResumeConfig{
+			MinLength: 20 * time.Minute,
+		},
+	}
+}
+
+var activeConfig atomic.Value
+var configErr atomic.Value
+
+type configError struct {
+	err error
+}
+
+func setConfigError(err error) {
+	configErr.Store(configError{err: err})
+}
+
+func getConfigError() error {
+	if e, ok := configErr.Load().(configError); ok {
+		return e.err
+	}
+
+	return nil
+}
+
+func init() {
+	c := defaultConfig()
+	f, err := newFormatter(c.Format.Title, c.Format.Message)
+	if err != nil {
+		log.Fatalf("config: Could not parse default formats: %s", err)
+	}
+	c.formats = f
+
+	activeConfig.Store(&c)
+}
+
+func getConfig() *Config {
+	return activeConfig.Load().(*Config)
+}
+
+func defaultConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+
+	return path.Join(dir, "rofi-media", "config.toml")
+}
+
+func loadConfig(p string) (*Config, error) {
+	c := defaultConfig()
+
+	if p != "" {
+		md, err := toml.DecodeFile(p, &c)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("config: %w", err)
+		}
+
+		if undecoded := md.Undecoded(); len(undecoded) > 0 {
+			keys := make([]string, len(undecoded))
+			for i, k := range undecoded {
+				keys[i] = k.String()
+			}
+			return nil, fmt.Errorf("config: unknown keys: %s", strings.Join(keys, ", "))
+		}
+	}
+
+	f, err := newFormatter(c.Format.Title, c.Format.Message)
+	if err != nil {
+		return nil, fmt.Errorf("config: %w", err)
+	}
+	c.formats = f
+
+	if err := compileRules(c.Rules); err != nil {
+		return nil, fmt.Errorf("config: %w", err)
+	}
+
+	if err := compilePolicies(c.Policies); err != nil {
+		return nil, fmt.Errorf("config: %w", err)
+	}
+
+	if err := compileInhibitRules(c.Inhibit); err != nil {
+		return nil, fmt.Errorf("config: %w", err)
+	}
+
+	if c.Scrobble.Enabled && (c.Scrobble.URL == "" || c.Scrobble.Token == "") {
+		return nil, fmt.Errorf("config: scrobble needs a url and a token")
+	}
+
+	return &c, nil
+}
+
+// watchConfig polls the config file and calls onReload whenever it has been changed.
+// A failed reload keeps the previous config active and passes the error along.
+func watchConfig(p string, onReload func(err error)) {
+	if p == "" {
+		return
+	}
+
+	var lastMod time.Time
+	if info, err := os.Stat(p); err == nil {
+		lastMod = info.ModTime()
+	}
+
+	go func() {
+		for range time.Tick(configReloadInterval) {
+			var modTime time.Time
+			if info, err := os.Stat(p); err == nil {
+				modTime = info.ModTime()
+			}
+
+			if modTime.Equal(lastMod) {
+				continue
+			}
+			lastMod = modTime
+
+			c, err := loadConfig(p)
+			if err != nil {
+				log.Printf("Could not reload config: %s", err)
+				onReload(err)
+				continue
+			}
+
+			log.Printf("Reloaded config from %s\n", p)
+			activeConfig.Store(c)
+			onReload(nil)
+		}
+	}()
+}
+
+func (c *Config) playerIcon(name string) string {
+	keys := make([]string, 0, len(c.Icons.Players))
+	for k := range c.Icons.Players {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		if strings.Contains(name, k) {
+			return c.Icons.Players[k]
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/ingentingalls/rofi-media/art"
+	"github.com/ingentingalls/rofi-media/mpris"
+)
+
+// daemonBusName is owned by the daemon, so other instances can tell that it's running
+const daemonBusName = "com.github.ingentingalls.RofiMedia"
+
+// runDaemon keeps running in the background and reacts to players no matter how they were controlled
+func runDaemon() {
+	players := &playerList{}
+	d := newDucker()
+	sleep := newSleepTimer(players)
+	scrobbles := newScrobbler()
+	listens := newListenTracker(recordHistory, scrobbles.Add)
+	resumes := newResumer()
+
+	conn, err := dbus.SessionBus()
+	if err != nil {
+		log.Fatalf("daemon: could not create a connection to the bus: %s", err)
+	}
+
+	// Exported before the name is taken, so clients never find the daemon without its methods
+	if err := exportDaemonService(conn, &daemonService{players: players, sleep: sleep, resumes: resumes}); err != nil {
+		log.Fatalf("daemon: %s", err)
+	}
+
+	if err := requestDaemonName(conn); err != nil {
+		log.Fatalf("daemon: %s", err)
+	}
+
+	in := newInhibitor(conn)
+
+	// Notifications need the art too, so the daemon keeps its own fetcher on the same cache
+	artCache = art.NewCache("", 0, 0)
+	artFetcher = art.NewFetcher(artCache, artFetchWorkers, artFetchTimeout, func() {})
+	configureArt(getConfig().Art)
+
+	n, err := newNotifier(conn, onNotificationAction(players))
+	if err != nil {
+		log.Printf("Could not enable notifications: %s", err)
+	}
+
+	onDisconnect := func(name string) {
+		d.Forget(name)
+		d.Restore(name)
+		in.Update(players.All())
+		listens.Finish(name)
+		resumes.Finish(name)
+	}
+
+	rediscover, err := watchPlayers(conn, players, onDisconnect, onDaemonPropertyChange(players, d, in, n, listens, resumes))
+	if err != nil {
+		log.Fatalf("daemon: %s", err)
+	}
+
+	in.Update(players.All())
+	for _, p := range players.All() {
+		listens.Update(p)
+		resumes.Update(p)
+	}
+	watchLock(conn, players)
+	watchAlarms(players)
+	scrobbles.Run()
+
+	watchConfig(*configPath, func(err error) {
+		setConfigError(err)
+		configureArt(getConfig().Art)
+		rediscover()
+		in.Update(players.All())
+		scrobbles.Wake()
+	})
+
+	log.Printf("Watching %d players\n", len(players.All()))
+
+	signalCh := make(chan os.Signal, 1)
+	signal.Notify(signalCh, os.Interrupt, syscall.SIGTERM)
+	<-signalCh
+
+	// Players in the middle of a fade would be left quiet
+	fading.StopAll()
+
+	// Tracks that are still playing are recorded as far as they got
+	listens.FinishAll()
+	resumes.FinishAll()
+}
+
+func requestDaemonName(conn *dbus.Conn) error {
+	reply, err := conn.RequestName(daemonBusName, dbus.NameFlagDoNotQueue)
+	if err != nil {
+		return fmt.Errorf("could not request %s: %w", daemonBusName, err)
+	}
+	if reply != dbus.RequestNameReplyPrimaryOwner {
+		return fmt.Errorf("another daemon is already running")
+	}
+
+	return nil
+}
+
+func onDaemonPropertyChange(players *playerList, d *ducker, in *inhibitor, n *notifier, listens *listenTracker, resumes *resumer) func(name string, changedProperties []string) {
+	return func(name string, changedProperties []string) {
+		if n != nil && getConfig().Features.Notifications && hasProperty(changedProperties, "Metadata") {
+			// Waits for the art, so it can't hold up the signal. Notify drops notifications for old tracks
+			if selected, _ := separatePlayers(players.All(), name); selected != nil && selected.IsPlaying() {
+				go func(p mpris.Player) {
+					if err := n.Notify(p); err != nil {
+						log.Printf("Could not send notification (%s): %s", p.Name, err)
+					}
+				}(*selected)
+			}
+		}
+
+		if hasProperty(changedProperties, "PlaybackStatus") || hasProperty(changedProperties, "Metadata") || hasProperty(changedProperties, "Position") || hasProperty(changedProperties, "Rate") {
+			if selected, _ := separatePlayers(players.All(), name); selected != nil {
+				resumes.Update(*selected)
+			}
+		}
+
+		if hasProperty(changedProperties, "PlaybackStatus") || hasProperty(changedProperties, "Metadata") {
+			in.Update(players.All())
+
+			if selected, _ := separatePlayers(players.All(), name); selected != nil {
+				listens.Update(*selected)
+			}
+		}
+
+		if !hasProperty(changedProperties, "PlaybackStatus") {
+			return
+		}
+
+		selected, others := separatePlayers(players.All(), name)
+		if selected == nil {
+			return
+		}
+
+		if !selected.IsPlaying() {
+			d.Restore(name)
+			return
+		}
+
+		// Fades take seconds, and the player's later signals queue up behind this callback
+		if getConfig().Features.ExclusivePlayback {
+			go pauseOthers(*selected, others, d)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package desktop
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+type Entry struct {
+	ID   string
+	Name string
+	Icon string
+	Path string
+}
+
+var (
+	entries   = map[string]*Entry{}
+	entriesMu sync.Mutex
+)
+
+// Lookup finds the .desktop file for an MPRIS DesktopEntry in the XDG applications directories.
+// Results, including misses, are cached for the lifetime of the process.
+func Lookup(id string) (Entry, bool) {
+	id = strings.TrimSuffix(id, ".desktop")
+	if id == "" {
+		return Entry{}, false
+	}
+
+	entriesMu.Lock()
+	defer entriesMu.Unlock()
+
+	e, ok := entries[id]
+	if !ok {
+		e = find(id)
+		entries[id] = e
+	}
+
+	if e == nil {
+		return Entry{}, false
+	}
+
+	return *e, true
+}
+
+// dataDirs returns XDG_DATA_HOME followed by XDG_DATA_DIRS, in order of preference
+func dataDirs() []string {
+	var dirs []string
+
+	if home := os.Getenv("XDG_DATA_HOME"); home != "" {
+		dirs = append(dirs, home)
+	} else if home, err := os.UserHomeDir(); err == nil {
+		dirs = append(dirs, filepath.Join(home, ".local", "share"))
+	}
+
+	dataDirs := os.Getenv("XDG_DATA_DIRS")
+	if dataDirs == "" {
+		dataDirs = "/usr/local/share:/usr/share"
+	}
+
+	for _, d := range filepath.SplitList(dataDirs) {
+		if d != "" {
+			dirs = append(dirs, d)
+		}
+	}
+
+	return dirs
+}
+
+// candidates returns the paths a desktop file id can be stored at.
+// Dashes in the id may stand for subdirectories, e.g. kde4-foo is kde4/foo.desktop
+func candidates(id string) []string {
+	paths := []string{id + ".desktop"}
+
+	parts := strings.Split(id, "-")
+	for i := 1; i < len(parts); i++ {
+		dir := filepath.Join(parts[:i]...)
+		paths = append(paths, filepath.Join(dir, strings.Join(parts[i:], "-")+".desktop"))
+	}
+
+	return paths
+}
+
+func find(id string) *Entry {
+	for _, dir := range dataDirs() {
+		for _, c := range candidates(id) {
+			p := filepath.Join(dir, "applications", c)
+			if e, err := parse(p); err == nil {
+				e.ID = id
+				return e
+			}
+		}
+	}
+
+	return nil
+}
+
+func parse(p string) (*Entry, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	e := &Entry{Path: p}
+	isMainSection := false
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") {
+			isMainSection = line == "[Desktop Entry]"
+			continue
+		}
+		if !isMainSection {
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+
+		switch strings.TrimSpace(key) {
+		case "Name":
+			e.Name = strings.TrimSpace(value)
+		case "Icon":
+			e.Icon = strings.TrimSpace(value)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return e, nil
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/ingentingalls/rofi-media/mpris"
+)
+
+const defaultDuckVolume = 0.2
+
+type duckedPlayer struct {
+	player mpris.Player
+	volume float64
+	// by is the player that caused the ducking
+	by string
+}
+
+// ducker lowers the volume of players and remembers their volume until they are restored
+type ducker struct {
+	ducked map[string]duckedPlayer
+
+	sync.Mutex
+}
+
+func newDucker() *ducker {
+	return &ducker{ducked: map[string]duckedPlayer{}}
+}
+
+// Duck lowers the volume of p to volume because the player named by started playing
+func (d *ducker) Duck(p mpris.Player, by string, volume float64) error {
+	d.Lock()
+	defer d.Unlock()
+
+	// Reading the volume again would remember the ducked volume
+	if dp, ok := d.ducked[p.Name]; ok {
+		dp.by = by
+		d.ducked[p.Name] = dp
+		return nil
+	}
+
+	if !p.CanSetVolume() {
+		return fmt.Errorf("duck: %w", mpris.ErrUnsupported)
+	}
+
+	current, err := p.GetVolume()
+	if err != nil {
+		return fmt.Errorf("duck: %w", err)
+	}
+	if current <= volume {
+		return nil
+	}
+
+	if err := p.SetVolume(volume); err != nil {
+		return fmt.Errorf("duck: %w", err)
+	}
+
+	d.ducked[p.Name] = duckedPlayer{player: p, volume: current, by: by}
+	return nil
+}
+
+// Restore sets the volume back for every player that was ducked by the named player
+func (d *ducker) Restore(by string) {
+	d.Lock()
+	defer d.Unlock()
+
+	for name, dp := range d.ducked {
+		if dp.by != by {
+			continue
+		}
+
+		if err := dp.player.SetVolume(dp.volume); err != nil {
+			log.Printf("Could not restore volume (%s): %s", name, err)
+		}
+		delete(d.ducked, name)
+	}
+}
+
+// Forget drops a player that went away without restoring it
+func (d *ducker) Forget(name string) {
+	d.Lock()
+	defer d.Unlock()
+
+	delete(d.ducked, name)
+}
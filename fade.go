@@ -0,0 +1,224 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/ingentingalls/rofi-media/mpris"
+)
+
+const fadeInterval = 50 * time.Millisecond
+
+// Players pause asynchronously, so the volume is restored a little later to not be heard
+const fadeRestoreDelay = 250 * time.Millisecond
+
+var errFadeStopped = errors.New("fade: stopped")
+
+// fadeTracker remembers the volume of the players being faded, so it can be set back when the daemon is stopped in the middle of a fade.
+// A player that's faded again before the first fade finished keeps the volume it had before both.
+type fadeTracker struct {
+	players map[string]*fadingPlayer
+	stopped bool
+
+	sync.Mutex
+}
+
+type fadingPlayer struct {
+	player mpris.Player
+	volume float64
+	fades  int
+}
+
+var fading = &fadeTracker{players: map[string]*fadingPlayer{}}
+
+// begin returns the volume to go back to after the fade
+func (t *fadeTracker) begin(p mpris.Player, volume float64) float64 {
+	t.Lock()
+	defer t.Unlock()
+
+	if f, ok := t.players[p.Name]; ok {
+		f.fades++
+		return f.volume
+	}
+	t.players[p.Name] = &fadingPlayer{player: p, volume: volume, fades: 1}
+
+	return volume
+}
+
+func (t *fadeTracker) end(name string) {
+	t.Lock()
+	defer t.Unlock()
+
+	if f, ok := t.players[name]; ok {
+		f.fades--
+		if f.fades <= 0 {
+			delete(t.players, name)
+		}
+	}
+}
+
+func (t *fadeTracker) isStopped() bool {
+	t.Lock()
+	defer t.Unlock()
+
+	return t.stopped
+}
+
+// StopAll stops the fades and sets the volumes back
+func (t *fadeTracker) StopAll() {
+	t.Lock()
+	defer t.Unlock()
+
+	t.stopped = true
+	for name, f := range t.players {
+		if err := f.player.SetVolume(f.volume); err != nil {
+			log.Printf("Could not restore volume (%s): %s", name, err)
+		}
+		delete(t.players, name)
+	}
+}
+
+// pausePlayers pauses the players together.
+// Rofi exits as soon as it's closed, which would leave a player silent in the middle of a fade,
+// so it asks the daemon to fade them and pauses them right away when there's no daemon.
+func pausePlayers(players []mpris.Player) {
+	if daemon != nil {
+		if err := daemon.Pause(playerNames(players)); err != nil {
+			eachPlayer(players, "pause", mpris.Player.Pause)
+		}
+		return
+	}
+
+	eachPlayer(players, "pause", pausePlayer)
+}
+
+// playPlayers starts the players together, like pausePlayers
+func playPlayers(players []mpris.Player) {
+	if daemon != nil {
+		if err := daemon.Play(playerNames(players)); err != nil {
+			eachPlayer(players, "play", mpris.Player.Play)
+		}
+		return
+	}
+
+	eachPlayer(players, "play", playPlayer)
+}
+
+func eachPlayer(players []mpris.Player, action string, f func(mpris.Player) error) {
+	var wg sync.WaitGroup
+	for _, p := range players {
+		wg.Add(1)
+		go func(p mpris.Player) {
+			defer wg.Done()
+			if err := f(p); err != nil {
+				log.Printf("Could not %s (%s): %s", action, p.Name, err)
+			}
+		}(p)
+	}
+	wg.Wait()
+}
+
+func playerNames(players []mpris.Player) []string {
+	names := make([]string, 0, len(players))
+	for _, p := range players {
+		names = append(names, p.Name)
+	}
+
+	return names
+}
+
+// pausePlayer pauses p, fading it out first when a pause fade is configured
+func pausePlayer(p mpris.Player) error {
+	if !p.IsPlaying() {
+		return p.Pause()
+	}
+
+	return fadeOutAndPause(p, getConfig().Fade.Pause)
+}
+
+// playPlayer starts p quietly and fades it in when a play fade is configured
+func playPlayer(p mpris.Player) error {
+	if p.IsPlaying() {
+		return nil
+	}
+
+	return fadeIn(p, getConfig().Fade.Play, p.Play)
+}
+
+// fadeIn calls start while the player is silent, and ramps the volume back up over d
+func fadeIn(p mpris.Player, d time.Duration, start func() error) error {
+	if d <= 0 || !p.CanSetVolume() {
+		return start()
+	}
+
+	volume, err := p.GetVolume()
+	if err != nil {
+		return start()
+	}
+	volume = fading.begin(p, volume)
+	defer fading.end(p.Name)
+
+	if err := p.SetVolume(0); err != nil {
+		return start()
+	}
+
+	if err := start(); err != nil {
+		p.SetVolume(volume)
+		return err
+	}
+
+	return fadeVolume(p, 0, volume, d)
+}
+
+// fadeVolume ramps the volume of p from one level to another over d
+func fadeVolume(p mpris.Player, from, to float64, d time.Duration) error {
+	steps := int(d / fadeInterval)
+	for i := 1; i <= steps; i++ {
+		if fading.isStopped() {
+			return errFadeStopped
+		}
+		v := from + (to-from)*float64(i)/float64(steps)
+		if err := p.SetVolume(v); err != nil {
+			return fmt.Errorf("fade: %w", err)
+		}
+		time.Sleep(fadeInterval)
+	}
+
+	if err := p.SetVolume(to); err != nil {
+		return fmt.Errorf("fade: %w", err)
+	}
+
+	return nil
+}
+
+// fadeOutAndPause fades the volume out over d, pauses the player and sets the volume back.
+// Players without volume control are paused right away.
+func fadeOutAndPause(p mpris.Player, d time.Duration) error {
+	if d <= 0 || !p.CanSetVolume() {
+		return p.Pause()
+	}
+
+	volume, err := p.GetVolume()
+	if err != nil {
+		return p.Pause()
+	}
+	volume = fading.begin(p, volume)
+	defer fading.end(p.Name)
+
+	fadeErr := fadeVolume(p, volume, 0, d)
+
+	if err := p.Pause(); err != nil {
+		p.SetVolume(volume)
+		return err
+	}
+
+	time.Sleep(fadeRestoreDelay)
+	if err := p.SetVolume(volume); err != nil {
+		return err
+	}
+
+	return fadeErr
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"log"
+	"path"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/ingentingalls/rofi-media/mpris"
+)
+
+const defaultTitleFormat = `{{.StatusIcon}} {{if .Title}}{{escape .Title}}{{if .Artist}}` + "\r" + `{{escape .Artist}}{{end}}{{else if .URL}}{{escape (base .URL)}}{{else}}{{escape .DisplayName}}{{end}}`
+const defaultMessageFormat = `{{if .Title}}{{escape .Title}}{{if .Artist}}` + "\r" + `{{escape .Artist}}{{end}}{{else if .URL}}{{escape (base .URL)}}{{else}}{{escape .DisplayName}}{{end}}` +
+	`{{if .Length}}` + "\r" + `{{duration .Position}} / {{duration .Length}}  {{.ProgressBar 20}}{{end}}`
+
+const progressBarFilled = "━"
+const progressBarEmpty = "─"
+
+type formatter struct {
+	title   *template.Template
+	message *template.Template
+}
+
+var formatFuncs = template.FuncMap{
+	"escape":   html.EscapeString,
+	"duration": formatDuration,
+	"truncate": truncate,
+	"base":     path.Base,
+	"upper":    strings.ToUpper,
+	"lower":    strings.ToLower,
+}
+
+func newFormatter(titleFormat, messageFormat string) (*formatter, error) {
+	title, err := template.New("title").Funcs(formatFuncs).Parse(titleFormat)
+	if err != nil {
+		return nil, fmt.Errorf("format: invalid title format: %w", err)
+	}
+
+	message, err := template.New("message").Funcs(formatFuncs).Parse(messageFormat)
+	if err != nil {
+		return nil, fmt.Errorf("format: invalid message format: %w", err)
+	}
+
+	return &formatter{title: title, message: message}, nil
+}
+
+type formatData struct {
+	mpris.Media
+
+	Name        string
+	Short       string
+	DisplayName string
+	Identity    string
+	Status      mpris.PlaybackStatus
+
+	// Colours of the album art. Empty unless art colours are enabled and have been computed
+	Color       string
+	AccentColor string
+
+	player mpris.Player
+}
+
+func newFormatData(p mpris.Player) formatData {
+	colors, _ := artColors(p)
+
+	return formatData{
+		Media:       p.GetMetadata(),
+		Name:        p.Name,
+		Short:       p.Short,
+		DisplayName: getConfig().displayName(p),
+		Identity:    p.Identity,
+		Status:      p.GetPlaybackStatus(),
+		Color:       colors.Dominant,
+		AccentColor: colors.Accent,
+		player:      p,
+	}
+}
+
+func (d formatData) Playing() bool {
+	return d.Status == mpris.PlaybackStatusPlaying
+}
+
+func (d formatData) Position() time.Duration {
+	return d.player.Position()
+}
+
+func (d formatData) Remaining() time.Duration {
+	if d.Length == 0 {
+		return 0
+	}
+
+	remaining := d.Length - d.Position()
+	if remaining < 0 {
+		return 0
+	}
+
+	return remaining
+}
+
+func (d formatData) ProgressBar(width int) string {
+	if d.Length <= 0 || width <= 0 {
+		return ""
+	}
+
+	filled := int(int64(width) * int64(d.Position()) / int64(d.Length))
+	if filled > width {
+		filled = width
+	}
+
+	return strings.Repeat(progressBarFilled, filled) + strings.Repeat(progressBarEmpty, width-filled)
+}
+
+func (d formatData) StatusIcon() string {
+	switch d.Status {
+	case mpris.PlaybackStatusPlaying:
+		return ""
+	case mpris.PlaybackStatusStopped:
+		return ""
+	default:
+		return ""
+	}
+}
+
+func (f *formatter) execute(t *template.Template, p mpris.Player) string {
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, newFormatData(p)); err != nil {
+		log.Printf("Could not format %s (%s): %s", t.Name(), p.Name, err)
+		return p.Short
+	}
+
+	return buf.String()
+}
+
+func (f *formatter) Title(p mpris.Player) string {
+	return f.execute(f.title, p)
+}
+
+func (f *formatter) Message(p mpris.Player) string {
+	return f.execute(f.message, p)
+}
+
+func formatDuration(d time.Duration) string {
+	d = d.Round(time.Second)
+	h := d / time.Hour
+	m := (d % time.Hour) / time.Minute
+	s := (d % time.Minute) / time.Second
+
+	if h > 0 {
+		return fmt.Sprintf("%d:%02d:%02d", h, m, s)
+	}
+
+	return fmt.Sprintf("%d:%02d", m, s)
+}
+
+func truncate(n int, s string) string {
+	r := []rune(s)
+	if n <= 0 || len(r) <= n {
+		return s
+	}
+
+	return string(r[:n-1]) + "…"
+}
@@ -3,6 +3,7 @@ module github.com/ingentingalls/rofi-media
 go 1.18
 
 require (
+	github.com/BurntSushi/toml v1.3.2
 	github.com/godbus/dbus/v5 v5.0.4
 	github.com/ingentingalls/rofi v0.1.0
 )
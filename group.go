@@ -0,0 +1,123 @@
+package main
+
+import (
+	"log"
+
+	"github.com/ingentingalls/rofi"
+	"github.com/ingentingalls/rofi-media/mpris"
+)
+
+const pausedStateFile = "paused.json"
+
+// pausedState remembers which players "Pause all" paused, so "Resume" can restart exactly those.
+// It's stored on disk since the resume usually happens in a later rofi session.
+type pausedState struct {
+	Players []string `json:"players"`
+}
+
+func loadPaused() pausedState {
+	var s pausedState
+	if err := readState(statePath(pausedStateFile), &s); err != nil {
+		log.Printf("Could not load paused players: %s", err)
+	}
+
+	return s
+}
+
+func savePaused(s pausedState) {
+	if err := writeState(statePath(pausedStateFile), s); err != nil {
+		log.Printf("Could not save paused players: %s", err)
+	}
+}
+
+// pauseAll pauses every playing player and returns the names of the players it paused
+func pauseAll(players []mpris.Player) []string {
+	var playing []mpris.Player
+	for _, p := range players {
+		if p.IsPlaying() {
+			playing = append(playing, p)
+		}
+	}
+	pausePlayers(playing)
+	paused := playerNames(playing)
+
+	// Pausing when nothing plays shouldn't forget what to resume
+	if len(paused) > 0 {
+		savePaused(pausedState{Players: paused})
+	}
+
+	return paused
+}
+
+func resumeAll(players []mpris.Player) {
+	s := loadPaused()
+
+	var paused []mpris.Player
+	for _, name := range s.Players {
+		selected, _ := separatePlayers(players, name)
+		if selected == nil {
+			log.Printf("Could not resume (%s): player is gone", name)
+			continue
+		}
+		paused = append(paused, *selected)
+	}
+	playPlayers(paused)
+
+	savePaused(pausedState{})
+}
+
+func stopAll(players []mpris.Player) {
+	for _, p := range players {
+		if p.GetPlaybackStatus() == mpris.PlaybackStatusStopped {
+			continue
+		}
+
+		if err := p.Stop(); err != nil {
+			log.Printf("Could not stop (%s): %s", p.Name, err)
+		}
+	}
+}
+
+func showGroupActions(players []mpris.Player) []rofi.Option {
+	c := getConfig()
+
+	var isAnyPlaying, isAnyActive bool
+	for _, p := range players {
+		isAnyPlaying = isAnyPlaying || p.IsPlaying()
+		isAnyActive = isAnyActive || p.GetPlaybackStatus() != mpris.PlaybackStatusStopped
+	}
+
+	var canResume bool
+	for _, name := range loadPaused().Players {
+		if selected, _ := separatePlayers(players, name); selected != nil && !selected.IsPlaying() {
+			canResume = true
+		}
+	}
+
+	var opts []rofi.Option
+	if isAnyPlaying {
+		opts = append(opts, rofi.Option{
+			Label: "Pause all",
+			Cmds:  []string{"pauseAll"},
+			Icon:  c.Icons.Pause,
+		})
+	}
+
+	if canResume {
+		opts = append(opts, rofi.Option{
+			Label: "Resume",
+			Cmds:  []string{"resumeAll"},
+			Icon:  c.Icons.Play,
+		})
+	}
+
+	if isAnyActive {
+		opts = append(opts, rofi.Option{
+			Label: "Stop all",
+			Cmds:  []string{"stopAll"},
+			Icon:  c.Icons.Stop,
+		})
+	}
+
+	return opts
+}
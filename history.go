@@ -0,0 +1,283 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ingentingalls/rofi"
+	"github.com/ingentingalls/rofi-media/mpris"
+)
+
+const historyStateFile = "history.jsonl"
+
+// Entries that don't fit in a line are skipped
+const maxHistoryLine = 1 << 20
+
+type historyEntry struct {
+	Player   string `json:"player"`
+	Identity string `json:"identity,omitempty"`
+
+	Started  time.Time     `json:"started"`
+	Listened time.Duration `json:"listened"`
+
+	TrackID     string        `json:"track_id,omitempty"`
+	Title       string        `json:"title,omitempty"`
+	Artist      string        `json:"artist,omitempty"`
+	Album       string        `json:"album,omitempty"`
+	AlbumArtist string        `json:"album_artist,omitempty"`
+	Genre       string        `json:"genre,omitempty"`
+	Year        int8          `json:"year,omitempty"`
+	Length      time.Duration `json:"length,omitempty"`
+	URL         string        `json:"url,omitempty"`
+	ArtURL      string        `json:"art_url,omitempty"`
+}
+
+func newHistoryEntry(l listen) historyEntry {
+	return historyEntry{
+		Player:   l.Player,
+		Identity: l.Identity,
+		Started:  l.Started,
+		Listened: l.Listened,
+
+		TrackID:     l.Media.ID,
+		Title:       l.Media.Title,
+		Artist:      l.Media.Artist,
+		Album:       l.Media.Album,
+		AlbumArtist: l.Media.AlbumArtist,
+		Genre:       l.Media.Genre,
+		Year:        l.Media.Year,
+		Length:      l.Media.Length,
+		URL:         l.Media.URL,
+		ArtURL:      l.Media.ArtURL,
+	}
+}
+
+// Media returns the track of the entry as the player described it
+func (e historyEntry) Media() mpris.Media {
+	return mpris.Media{
+		ID:          e.TrackID,
+		Title:       e.Title,
+		Artist:      e.Artist,
+		Album:       e.Album,
+		AlbumArtist: e.AlbumArtist,
+		Genre:       e.Genre,
+		Year:        e.Year,
+		Length:      e.Length,
+		URL:         e.URL,
+		ArtURL:      e.ArtURL,
+	}
+}
+
+var historyMu sync.Mutex
+
+// appendHistory adds an entry as a line of JSON, so a crash can at most lose the last line
+func appendHistory(e historyEntry) error {
+	historyMu.Lock()
+	defer historyMu.Unlock()
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("history: %w", err)
+	}
+
+	p := statePath(historyStateFile)
+	if err := os.MkdirAll(filepath.Dir(p), os.ModePerm); err != nil {
+		return fmt.Errorf("history: Error while creating path: %w", err)
+	}
+
+	f, err := os.OpenFile(p, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return fmt.Errorf("history: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("history: %w", err)
+	}
+
+	return f.Close()
+}
+
+// readHistory calls fn with every entry from the oldest to the newest.
+// Lines that can't be decoded are skipped.
+func readHistory(fn func(e historyEntry)) error {
+	f, err := os.Open(statePath(historyStateFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("history: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64<<10), maxHistoryLine)
+	for scanner.Scan() {
+		var e historyEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			continue
+		}
+		fn(e)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("history: %w", err)
+	}
+
+	return nil
+}
+
+// recentHistory returns up to n entries, newest first
+func recentHistory(n int) ([]historyEntry, error) {
+	var entries []historyEntry
+	err := readHistory(func(e historyEntry) {
+		entries = append(entries, e)
+		if len(entries) > n {
+			entries = entries[1:]
+		}
+	})
+
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+
+	return entries, err
+}
+
+func recordHistory(l listen) {
+	if !getConfig().History.Enabled {
+		return
+	}
+
+	if err := appendHistory(newHistoryEntry(l)); err != nil {
+		log.Printf("Could not record history (%s): %s", l.Player, err)
+	}
+}
+
+func showHistoryAction() []rofi.Option {
+	if !getConfig().History.Enabled {
+		return nil
+	}
+
+	return []rofi.Option{{
+		Label: "History",
+		Cmds:  []string{"history"},
+		Icon:  getConfig().Icons.History,
+	}}
+}
+
+func showHistory() []rofi.Option {
+	c := getConfig()
+
+	entries, err := recentHistory(c.History.Show)
+	if err != nil {
+		log.Printf("Could not load history: %s", err)
+	}
+
+	var opts []rofi.Option
+	for _, e := range entries {
+		title := e.Title
+		if title == "" {
+			title = e.URL
+		}
+
+		details := []string{e.Started.Format("Mon 15:04")}
+		if e.Artist != "" {
+			details = append([]string{e.Artist}, details...)
+		}
+		if e.Identity != "" {
+			details = append(details, e.Identity)
+		}
+
+		opts = append(opts, rofi.Option{
+			Label:    html.EscapeString(title),
+			Category: fmt.Sprintf("<span color=\"%s\">%s</span>", html.EscapeString(c.Colors.Category), html.EscapeString(strings.Join(details, " · "))),
+			Icon:     getHistoryIcon(e),
+			Value:    strconv.FormatInt(e.Started.UnixNano(), 10),
+			Cmds:     []string{"openHistory"},
+
+			IsMultiline: true,
+			UseMarkup:   true,
+		})
+	}
+
+	opts = append(opts, rofi.Option{
+		Label: "Back",
+		Cmds:  []string{"showAll"},
+		Icon:  c.Icons.Back,
+	})
+
+	return opts
+}
+
+func getHistoryIcon(e historyEntry) string {
+	if e.ArtURL != "" {
+		if icon := getIconFromURL(e.ArtURL); icon != "" {
+			return icon
+		}
+	}
+
+	return getConfig().Icons.History
+}
+
+// openHistory opens the track of an entry again in the player that played it.
+// Players with several instances get new bus names, so a player with the same Identity will do.
+func openHistory(players []mpris.Player, started string) error {
+	var entry *historyEntry
+	err := readHistory(func(e historyEntry) {
+		if strconv.FormatInt(e.Started.UnixNano(), 10) == started {
+			e := e
+			entry = &e
+		}
+	})
+	if err != nil {
+		return err
+	}
+	if entry == nil {
+		return fmt.Errorf("history: entry is gone")
+	}
+
+	return openMedia(players, entry.Player, entry.Identity, entry.URL)
+}
+
+// openMedia opens url in the named player, or in another player with the same Identity
+func openMedia(players []mpris.Player, name, identity, url string) error {
+	if url == "" {
+		return fmt.Errorf("open: the track has no url")
+	}
+
+	p, err := findPlayerFor(players, name, identity)
+	if err != nil {
+		return err
+	}
+
+	if !p.CanOpenUri(url) {
+		return fmt.Errorf("open: %s can't open %s: %w", p.Name, truncate(80, url), mpris.ErrUnsupported)
+	}
+
+	return p.OpenUri(url)
+}
+
+// findPlayerFor finds the named player, or another player with the same Identity
+func findPlayerFor(players []mpris.Player, name, identity string) (*mpris.Player, error) {
+	p := findPlayer(players, name)
+	if p == nil && identity != "" {
+		p = findPlayer(players, identity)
+	}
+	if p == nil {
+		return nil, fmt.Errorf("open: %s isn't running", name)
+	}
+
+	return p, nil
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"mime"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/ingentingalls/rofi-media/mpris"
+)
+
+const screenSaverDest = "org.freedesktop.ScreenSaver"
+const screenSaverObjectPath = "/org/freedesktop/ScreenSaver"
+
+// Used when the system has no MIME database
+var mediaTypes = map[string]string{
+	".avi":  "video/x-msvideo",
+	".m4v":  "video/mp4",
+	".mkv":  "video/x-matroska",
+	".mov":  "video/quicktime",
+	".mp4":  "video/mp4",
+	".webm": "video/webm",
+	".flac": "audio/flac",
+	".m4a":  "audio/mp4",
+	".mp3":  "audio/mpeg",
+	".ogg":  "audio/ogg",
+	".opus": "audio/ogg",
+}
+
+// InhibitConfig keeps the screensaver from activating while a matching player is playing.
+// The player patterns are optional when MimeType is set.
+type InhibitConfig struct {
+	PlayerMatch
+
+	// MimeType is matched against the MIME type guessed from the track's url, e.g. "video/*"
+	MimeType string `toml:"mime_type"`
+
+	mimeType func(s string) bool
+}
+
+func (ic *InhibitConfig) compile() error {
+	ic.mimeType = nil
+	if ic.MimeType != "" {
+		m, err := compilePattern(ic.MimeType, ic.Regex)
+		if err != nil {
+			return err
+		}
+		ic.mimeType = m
+
+		if ic.BusName == "" && ic.Identity == "" && ic.DesktopEntry == "" {
+			ic.matchers = nil
+			return nil
+		}
+	}
+
+	return ic.PlayerMatch.compile()
+}
+
+func compileInhibitRules(rules []InhibitConfig) error {
+	for i := range rules {
+		if err := rules[i].compile(); err != nil {
+			return fmt.Errorf("inhibit %d: %w", i+1, err)
+		}
+	}
+
+	return nil
+}
+
+func (ic InhibitConfig) matchesPlayer(p mpris.Player) bool {
+	if !ic.matches(p.Name, p.Identity, p.DesktopEntry) {
+		return false
+	}
+
+	return ic.mimeType == nil || ic.mimeType(mediaMimeType(p.GetMetadata()))
+}
+
+// mediaMimeType guesses the MIME type from the extension of the track's url
+func mediaMimeType(m mpris.Media) string {
+	u, err := url.Parse(m.URL)
+	if err != nil {
+		return ""
+	}
+
+	ext := strings.ToLower(path.Ext(u.Path))
+	if ext == "" {
+		return ""
+	}
+
+	if t := mime.TypeByExtension(ext); t != "" {
+		t, _, _ = strings.Cut(t, ";")
+		return t
+	}
+
+	return mediaTypes[ext]
+}
+
+// inhibitor holds a screensaver inhibit cookie while any player matching an inhibit rule is playing
+type inhibitor struct {
+	obj dbus.BusObject
+
+	cookie   uint32
+	isActive bool
+
+	sync.Mutex
+}
+
+func newInhibitor(conn *dbus.Conn) *inhibitor {
+	return &inhibitor{obj: conn.Object(screenSaverDest, screenSaverObjectPath)}
+}
+
+// Update takes or releases the inhibit cookie to match the players
+func (in *inhibitor) Update(players []mpris.Player) {
+	in.Lock()
+	defer in.Unlock()
+
+	var reason *mpris.Player
+	for _, p := range players {
+		if !p.IsPlaying() {
+			continue
+		}
+
+		for _, ic := range getConfig().Inhibit {
+			if ic.matchesPlayer(p) {
+				p := p
+				reason = &p
+				break
+			}
+		}
+		if reason != nil {
+			break
+		}
+	}
+
+	switch {
+	case reason != nil && !in.isActive:
+		var cookie uint32
+		err := in.obj.Call(screenSaverDest+".Inhibit", 0, "rofi-media", fmt.Sprintf("%s is playing", getConfig().displayName(*reason))).Store(&cookie)
+		if err != nil {
+			log.Printf("Could not inhibit the screensaver: %s", err)
+			return
+		}
+		in.cookie = cookie
+		in.isActive = true
+
+	case reason == nil && in.isActive:
+		if err := in.obj.Call(screenSaverDest+".UnInhibit", 0, in.cookie).Err; err != nil {
+			log.Printf("Could not uninhibit the screensaver: %s", err)
+		}
+		in.isActive = false
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ingentingalls/rofi-media/mpris"
+)
+
+// listen is a track as it was played by a player
+type listen struct {
+	Player   string
+	Identity string
+	Media    mpris.Media
+
+	Started  time.Time
+	Listened time.Duration
+
+	// playingSince is set while the track is playing
+	playingSince time.Time
+}
+
+func (l listen) listened(now time.Time) time.Duration {
+	if l.playingSince.IsZero() {
+		return l.Listened
+	}
+
+	return l.Listened + now.Sub(l.playingSince)
+}
+
+// listenTracker follows what every player plays and for how long.
+// onFinish is called when a player moves on to another track, or goes away.
+type listenTracker struct {
+	listens  map[string]*listen
+	onFinish []func(l listen)
+
+	sync.Mutex
+}
+
+func newListenTracker(onFinish ...func(l listen)) *listenTracker {
+	return &listenTracker{listens: map[string]*listen{}, onFinish: onFinish}
+}
+
+// Update follows the changes of a player
+func (t *listenTracker) Update(p mpris.Player) {
+	t.Lock()
+	var finished []listen
+	defer func() {
+		t.Unlock()
+		t.finish(finished...)
+	}()
+
+	now := time.Now()
+	m := p.GetMetadata()
+
+	l, ok := t.listens[p.Name]
+	if ok && !isSameTrack(l.Media, m) {
+		l.Listened = l.listened(now)
+		finished = append(finished, *l)
+		ok = false
+	}
+
+	if !ok {
+		if m.Title == "" && m.URL == "" {
+			delete(t.listens, p.Name)
+			return
+		}
+
+		l = &listen{Player: p.Name, Identity: p.Identity, Media: m, Started: now}
+		t.listens[p.Name] = l
+	}
+	l.Media = m
+
+	switch {
+	case p.IsPlaying() && l.playingSince.IsZero():
+		l.playingSince = now
+	case !p.IsPlaying() && !l.playingSince.IsZero():
+		l.Listened = l.listened(now)
+		l.playingSince = time.Time{}
+	}
+}
+
+// Finish ends the listen of a player that went away
+func (t *listenTracker) Finish(name string) {
+	t.Lock()
+	l, ok := t.listens[name]
+	delete(t.listens, name)
+	t.Unlock()
+
+	if ok {
+		l.Listened = l.listened(time.Now())
+		t.finish(*l)
+	}
+}
+
+// FinishAll ends every listen, e.g. when the daemon is stopped
+func (t *listenTracker) FinishAll() {
+	t.Lock()
+	var finished []listen
+	now := time.Now()
+	for name, l := range t.listens {
+		l.Listened = l.listened(now)
+		finished = append(finished, *l)
+		delete(t.listens, name)
+	}
+	t.Unlock()
+
+	t.finish(finished...)
+}
+
+func (t *listenTracker) finish(listens ...listen) {
+	for _, l := range listens {
+		// Tracks that never played aren't listens
+		if l.Listened <= 0 {
+			continue
+		}
+
+		for _, onFinish := range t.onFinish {
+			onFinish(l)
+		}
+	}
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sync"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/ingentingalls/rofi-media/mpris"
+)
+
+const screenSaverInterface = "org.freedesktop.ScreenSaver"
+const memberNameActiveChanged = "ActiveChanged"
+const signalNameActiveChanged = screenSaverInterface + "." + memberNameActiveChanged
+
+const login1Dest = "org.freedesktop.login1"
+const login1ObjectPath = "/org/freedesktop/login1"
+const login1ManagerInterface = "org.freedesktop.login1.Manager"
+const login1SessionInterface = "org.freedesktop.login1.Session"
+const signalNamePrepareForSleep = login1ManagerInterface + ".PrepareForSleep"
+const signalNameLock = login1SessionInterface + ".Lock"
+const signalNameUnlock = login1SessionInterface + ".Unlock"
+
+// lockWatcher pauses the players when the screen locks or the computer goes to sleep,
+// and resumes them afterwards when enabled
+type lockWatcher struct {
+	players *playerList
+
+	locked bool
+	// paused are the players paused by the lock, so players paused by hand aren't resumed.
+	// It's kept apart from "Pause all", which would otherwise forget them.
+	paused []string
+
+	// Replaced in tests
+	pausePlayers func(players []mpris.Player)
+	playPlayers  func(players []mpris.Player)
+
+	sync.Mutex
+}
+
+func newLockWatcher(players *playerList) *lockWatcher {
+	return &lockWatcher{players: players, pausePlayers: pausePlayers, playPlayers: playPlayers}
+}
+
+// watchLock listens for the screensaver on the session bus and for logind on the system bus.
+// Either one is enough, so a missing system bus is only logged.
+func watchLock(conn *dbus.Conn, players *playerList) {
+	w := newLockWatcher(players)
+
+	signalCh := make(chan *dbus.Signal, 8)
+
+	err := conn.AddMatchSignal(
+		dbus.WithMatchInterface(screenSaverInterface),
+		dbus.WithMatchMember(memberNameActiveChanged),
+	)
+	if err != nil {
+		log.Printf("Could not listen on the screensaver: %s", err)
+	}
+	conn.Signal(signalCh)
+
+	if err := watchLogind(signalCh); err != nil {
+		log.Printf("Could not listen on logind: %s", err)
+	}
+
+	go func() {
+		for msg := range signalCh {
+			w.handle(msg)
+		}
+	}()
+}
+
+func (w *lockWatcher) handle(msg *dbus.Signal) {
+	var active bool
+	switch msg.Name {
+	case signalNameActiveChanged, signalNamePrepareForSleep:
+		if len(msg.Body) != 1 {
+			return
+		}
+		if v, ok := msg.Body[0].(bool); ok {
+			active = v
+		}
+	case signalNameLock:
+		active = true
+	case signalNameUnlock:
+		active = false
+	default:
+		return
+	}
+
+	isSleep := msg.Name == signalNamePrepareForSleep
+	if active {
+		w.pause(!isSleep)
+	} else {
+		// The screen is usually still locked when waking up
+		w.resume(!isSleep)
+	}
+}
+
+func watchLogind(signalCh chan *dbus.Signal) error {
+	system, err := dbus.SystemBus()
+	if err != nil {
+		return fmt.Errorf("could not create a connection to the system bus: %w", err)
+	}
+
+	err = system.AddMatchSignal(
+		dbus.WithMatchObjectPath(login1ObjectPath),
+		dbus.WithMatchInterface(login1ManagerInterface),
+		dbus.WithMatchMember("PrepareForSleep"),
+	)
+	if err != nil {
+		return fmt.Errorf("could not listen on sleep: %w", err)
+	}
+	system.Signal(signalCh)
+
+	session, err := currentSession(system)
+	if err != nil {
+		return fmt.Errorf("could not find the session: %w", err)
+	}
+
+	err = system.AddMatchSignal(
+		dbus.WithMatchObjectPath(session),
+		dbus.WithMatchInterface(login1SessionInterface),
+	)
+	if err != nil {
+		return fmt.Errorf("could not listen on %s: %w", session, err)
+	}
+
+	return nil
+}
+
+// currentSession finds the logind session from the environment.
+// Daemons started by the user's service manager aren't part of a session, so the user's graphical session is used instead.
+func currentSession(system *dbus.Conn) (dbus.ObjectPath, error) {
+	var session dbus.ObjectPath
+
+	if id := os.Getenv("XDG_SESSION_ID"); id != "" {
+		manager := system.Object(login1Dest, login1ObjectPath)
+		err := manager.Call(login1ManagerInterface+".GetSession", 0, id).Store(&session)
+		return session, err
+	}
+
+	user := system.Object(login1Dest, login1ObjectPath+"/user/self")
+	prop, err := user.GetProperty("org.freedesktop.login1.User.Display")
+	if err != nil {
+		return session, err
+	}
+
+	var display struct {
+		ID   string
+		Path dbus.ObjectPath
+	}
+	if err := dbus.Store([]any{prop.Value()}, &display); err != nil {
+		return session, err
+	}
+	if display.ID == "" {
+		return session, fmt.Errorf("no graphical session")
+	}
+
+	return display.Path, nil
+}
+
+// pause pauses the playing players. The players fade out after the watcher is unlocked,
+// so the signals that come meanwhile aren't held up.
+func (w *lockWatcher) pause(locked bool) {
+	playing := w.startPause(locked)
+	if len(playing) == 0 {
+		return
+	}
+
+	w.pausePlayers(playing)
+	log.Printf("Paused %d players on lock\n", len(playing))
+}
+
+func (w *lockWatcher) startPause(locked bool) []mpris.Player {
+	w.Lock()
+	defer w.Unlock()
+
+	if locked {
+		w.locked = true
+	}
+	if !getConfig().Features.PauseOnLock {
+		return nil
+	}
+
+	// The screensaver and logind both signal the same lock, possibly while the players still fade out
+	var playing []mpris.Player
+	for _, p := range w.players.All() {
+		if p.IsPlaying() && !w.hasPaused(p.Name) {
+			playing = append(playing, p)
+		}
+	}
+	w.paused = append(w.paused, playerNames(playing)...)
+
+	return playing
+}
+
+// hasPaused expects the watcher to be locked
+func (w *lockWatcher) hasPaused(name string) bool {
+	for _, paused := range w.paused {
+		if paused == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (w *lockWatcher) resume(unlocked bool) {
+	resumed := w.startResume(unlocked)
+	if len(resumed) == 0 {
+		return
+	}
+
+	w.playPlayers(resumed)
+}
+
+func (w *lockWatcher) startResume(unlocked bool) []mpris.Player {
+	w.Lock()
+	defer w.Unlock()
+
+	if unlocked {
+		w.locked = false
+	}
+	if w.locked || len(w.paused) == 0 {
+		return nil
+	}
+	paused := w.paused
+	w.paused = nil
+
+	if !getConfig().Features.ResumeOnUnlock {
+		return nil
+	}
+
+	players := w.players.All()
+	var resumed []mpris.Player
+	for _, name := range paused {
+		selected, _ := separatePlayers(players, name)
+		if selected == nil {
+			log.Printf("Could not resume (%s): player is gone", name)
+			continue
+		}
+		resumed = append(resumed, *selected)
+	}
+
+	return resumed
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/ingentingalls/rofi-media/mpris"
+)
+
+func newTestPlayer(name string, status mpris.PlaybackStatus) mpris.Player {
+	p := mpris.Player{Name: name}
+	p.UpdateProperties(map[string]dbus.Variant{"PlaybackStatus": dbus.MakeVariant(string(status))})
+
+	return p
+}
+
+func setTestStatus(players []mpris.Player, status mpris.PlaybackStatus) {
+	for i := range players {
+		players[i].UpdateProperties(map[string]dbus.Variant{"PlaybackStatus": dbus.MakeVariant(string(status))})
+	}
+}
+
+func lockSignal(name string, body ...any) *dbus.Signal {
+	return &dbus.Signal{Name: name, Body: body}
+}
+
+func TestLockWatcher(t *testing.T) {
+	screensaver := func(active bool) *dbus.Signal { return lockSignal(signalNameActiveChanged, active) }
+	sleep := func(active bool) *dbus.Signal { return lockSignal(signalNamePrepareForSleep, active) }
+
+	type step struct {
+		signal *dbus.Signal
+		// remove makes a player quit before the signal
+		remove string
+	}
+
+	tests := []struct {
+		name         string
+		resumeUnlock bool
+		steps        []step
+		wantPaused   []string
+		wantResumed  []string
+	}{
+		{
+			name:         "screensaver",
+			resumeUnlock: true,
+			steps:        []step{{signal: screensaver(true)}, {signal: screensaver(false)}},
+			wantPaused:   []string{"a", "b"},
+			wantResumed:  []string{"a", "b"},
+		},
+		{
+			name:         "screensaver and logind signal the same lock",
+			resumeUnlock: true,
+			steps: []step{
+				{signal: screensaver(true)},
+				{signal: lockSignal(signalNameLock)},
+				{signal: lockSignal(signalNameUnlock)},
+				{signal: screensaver(false)},
+			},
+			wantPaused:  []string{"a", "b"},
+			wantResumed: []string{"a", "b"},
+		},
+		{
+			name:         "waking up resumes once the screen unlocks",
+			resumeUnlock: true,
+			steps:        []step{{signal: screensaver(true)}, {signal: sleep(true)}, {signal: sleep(false)}, {signal: screensaver(false)}},
+			wantPaused:   []string{"a", "b"},
+			wantResumed:  []string{"a", "b"},
+		},
+		{
+			name:         "waking up from sleep",
+			resumeUnlock: true,
+			steps:        []step{{signal: sleep(true)}, {signal: sleep(false)}},
+			wantPaused:   []string{"a", "b"},
+			wantResumed:  []string{"a", "b"},
+		},
+		{
+			name:         "players that quit aren't resumed",
+			resumeUnlock: true,
+			steps:        []step{{signal: screensaver(true)}, {signal: screensaver(false), remove: "a"}},
+			wantPaused:   []string{"a", "b"},
+			wantResumed:  []string{"b"},
+		},
+		{
+			name:       "resuming is disabled",
+			steps:      []step{{signal: screensaver(true)}, {signal: screensaver(false)}},
+			wantPaused: []string{"a", "b"},
+		},
+		{
+			name:         "other signals",
+			resumeUnlock: true,
+			steps:        []step{{signal: lockSignal(screenSaverInterface + ".WakeUpScreen")}, {signal: lockSignal(signalNameActiveChanged)}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			previous := getConfig()
+			c := *previous
+			c.Features.PauseOnLock = true
+			c.Features.ResumeOnUnlock = tt.resumeUnlock
+			activeConfig.Store(&c)
+			t.Cleanup(func() { activeConfig.Store(previous) })
+
+			list := &playerList{}
+			list.add(newTestPlayer("a", mpris.PlaybackStatusPlaying))
+			list.add(newTestPlayer("b", mpris.PlaybackStatusPlaying))
+			// Paused by hand, so it's never resumed
+			list.add(newTestPlayer("c", mpris.PlaybackStatusPaused))
+
+			var paused, resumed []string
+			w := newLockWatcher(list)
+			w.pausePlayers = func(players []mpris.Player) {
+				paused = append(paused, playerNames(players)...)
+				setTestStatus(players, mpris.PlaybackStatusPaused)
+			}
+			w.playPlayers = func(players []mpris.Player) {
+				resumed = append(resumed, playerNames(players)...)
+				setTestStatus(players, mpris.PlaybackStatusPlaying)
+			}
+
+			for _, s := range tt.steps {
+				if s.remove != "" {
+					list.remove(s.remove)
+				}
+				w.handle(s.signal)
+			}
+
+			sort.Strings(paused)
+			sort.Strings(resumed)
+			if !reflect.DeepEqual(paused, tt.wantPaused) {
+				t.Errorf("paused %v, want %v", paused, tt.wantPaused)
+			}
+			if !reflect.DeepEqual(resumed, tt.wantResumed) {
+				t.Errorf("resumed %v, want %v", resumed, tt.wantResumed)
+			}
+			if len(w.paused) != 0 {
+				t.Errorf("still remembers %v as paused", w.paused)
+			}
+		})
+	}
+}
+
+func TestLockWatcherUnlockDuringFade(t *testing.T) {
+	previous := getConfig()
+	c := *previous
+	c.Features.PauseOnLock = true
+	c.Features.ResumeOnUnlock = true
+	activeConfig.Store(&c)
+	t.Cleanup(func() { activeConfig.Store(previous) })
+
+	list := &playerList{}
+	list.add(newTestPlayer("a", mpris.PlaybackStatusPlaying))
+
+	var resumed []string
+	w := newLockWatcher(list)
+	w.playPlayers = func(players []mpris.Player) {
+		resumed = append(resumed, playerNames(players)...)
+	}
+	// The screen unlocks while the players still fade out
+	w.pausePlayers = func(players []mpris.Player) {
+		w.handle(lockSignal(signalNameActiveChanged, false))
+	}
+
+	done := make(chan struct{})
+	go func() {
+		w.handle(lockSignal(signalNameActiveChanged, true))
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("the unlock waited for the fade")
+	}
+
+	if !reflect.DeepEqual(resumed, []string{"a"}) {
+		t.Errorf("resumed %v, want [a]", resumed)
+	}
+}
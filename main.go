@@ -1,20 +1,20 @@
 package main
 
 import (
-	"context"
+	"flag"
 	"fmt"
 	"html"
-	"io"
-	"io/fs"
 	"log"
-	"net/http"
-	"os"
-	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/godbus/dbus/v5"
 	"github.com/ingentingalls/rofi"
+	"github.com/ingentingalls/rofi-media/art"
+	"github.com/ingentingalls/rofi-media/desktop"
 	"github.com/ingentingalls/rofi-media/mpris"
 )
 
@@ -24,12 +24,51 @@ const dbusObjectPath = "/org/freedesktop/DBus"
 const memberNameOwnerChanged = "NameOwnerChanged"
 const signalNameOwnerChanged = dbusInterface + "." + memberNameOwnerChanged
 
+var configPath = flag.String("config", defaultConfigPath(), "path to the config file")
+
+const artFetchWorkers = 4
+const artFetchTimeout = 5 * time.Second
+
+var artCache *art.Cache
+var artFetcher *art.Fetcher
+var daemon *daemonClient
+
 func main() {
-	var players []mpris.Player
+	flag.Parse()
+
+	if c, err := loadConfig(*configPath); err != nil {
+		log.Printf("Could not load config: %s", err)
+		setConfigError(err)
+	} else {
+		activeConfig.Store(c)
+	}
+
+	switch cmd := flag.Arg(0); cmd {
+	case "":
+		runRofi()
+	case "daemon":
+		runDaemon()
+	case "sleep":
+		runSleep(flag.Args()[1:])
+	case "alarm":
+		runAlarm(flag.Args()[1:])
+	case "stats":
+		runStats(flag.Args()[1:])
+	case "bookmark":
+		runBookmark(flag.Args()[1:])
+	default:
+		log.Fatalf("main: unknown command %q", cmd)
+	}
+}
+
+func runRofi() {
+	list := &playerList{}
 	var currentView rofi.Value
+	var progress progressTicker
+	renders := newRenderQueue()
 
 	model, eventCh := rofi.NewRofiBlock()
-	model.Prompt = "Players"
+	model.Prompt = getConfig().Prompt
 	model.Message = "Loading players..."
 	model.Render()
 
@@ -38,100 +77,70 @@ func main() {
 		log.Fatalf("dbusnotify: could not create a connection to the bus: %s", err)
 	}
 
-	conn.AddMatchSignal(
-		dbus.WithMatchObjectPath(dbusObjectPath),
-		dbus.WithMatchInterface(dbusInterface),
-		dbus.WithMatchMember(memberNameOwnerChanged),
-	)
+	daemon = newDaemonClient(conn)
 
-	signalCh := make(chan *dbus.Signal)
-	conn.Signal(signalCh)
+	artCache = art.NewCache("", 0, 0)
+	artFetcher = art.NewFetcher(artCache, artFetchWorkers, artFetchTimeout, renders.All)
+	configureArt(getConfig().Art)
+	artCache.Evict()
 
-	go func() {
-		for {
-			msg, ok := <-signalCh
-			if !ok {
-				log.Println("not ok")
-				break
-			}
-			if msg.Name != signalNameOwnerChanged {
-				continue
-			}
-			if len(msg.Body) != 3 {
-				log.Printf("main: Object received didnt have enough args for %s. Wanted %d, got %d", signalNameOwnerChanged, 3, len(msg.Body))
-				continue
-			}
-			if name, ok := msg.Body[0].(string); ok && mpris.HasValidDestinationName(name) {
-				if ownerID, ok := msg.Body[2].(string); ok && ownerID != "" {
-					log.Printf("Discovered new player: %s\n", name)
-					player, err := mpris.NewPlayer(conn, name, ownerID, onDisconnect(&players, &model, &currentView), onPropertyChange(&players, &model, &currentView))
-					if err != nil {
-						log.Printf("Could not create a new player from %s: %s", name, err)
-					}
-					players = append(players, player)
-				}
-			}
-		}
-	}()
-	obj := conn.Object(dbusDest, dbusObjectPath)
-
-	/*obj2 := conn.Object("org.mpris.MediaPlayer2.spotify", "/org/mpris/MediaPlayer2")
-	introspectResp, _ := introspect.Call(obj2)
-	introspectJson, _ := json.MarshalIndent(introspectResp, "", "  ")
-	log.Fatalln(string(introspectJson))
-	*/
-	resp := obj.Call("org.freedesktop.DBus.ListNames", dbus.Flags(0))
-	if resp.Err != nil {
-		log.Fatalf("listnames: %s", resp.Err)
+	rediscover, err := watchPlayers(conn, list, onDisconnect(renders), onPropertyChange(renders))
+	if err != nil {
+		log.Fatalf("main: %s", err)
 	}
 
-	var names []string
-	if err := resp.Store(&names); err != nil {
-		log.Fatalf("could not get names: %s", err)
-	}
+	model.Options = showAllPlayers(list.All())
+	model.Message = withConfigError(sleepMessage())
+	model.Render()
+	updateProgress(&progress, renders, list.All(), currentView)
+
+	watchConfig(*configPath, func(err error) {
+		setConfigError(err)
+		configureArt(getConfig().Art)
+		rediscover()
+		renders.All()
+	})
 
-	for _, name := range names {
-		if mpris.HasValidDestinationName(name) {
-			var ownerID string
-			ownerResp := obj.Call("org.freedesktop.DBus.GetNameOwner", 0, name)
-			if err := ownerResp.Store(&ownerID); err != nil {
-				log.Printf("Couldn't find owner for %s: %s", name, err)
+	for {
+		var v rofi.Value
+		select {
+		case v = <-eventCh:
+		case <-renders.players:
+			// Other views don't list the players
+			if currentView.Cmd == "controls" || currentView.Cmd == "" {
+				renderView(list.All(), &model, currentView)
 			}
-			player, err := mpris.NewPlayer(conn, name, ownerID, onDisconnect(&players, &model, &currentView), onPropertyChange(&players, &model, &currentView))
-			if err != nil {
-				log.Printf("Could not create a new player from %s: %s", name, err)
+			continue
+		case <-renders.playback:
+			// A paused player doesn't move, so the progress only ticks while it plays
+			if currentView.Cmd == "controls" || currentView.Cmd == "" {
+				renderView(list.All(), &model, currentView)
+				updateProgress(&progress, renders, list.All(), currentView)
 			}
-			players = append(players, player)
+			continue
+		case <-renders.all:
+			renderView(list.All(), &model, currentView)
+			continue
 		}
-	}
-
-	model.Options = showAllPlayers(players)
-	model.Message = " "
-	model.Render()
-
-	for {
-		v := <-eventCh
+		players := list.All()
 
 		selected, others := separatePlayers(players, v.Value)
 
 		switch v.Cmd {
 		case "pause":
-			if err := selected.Pause(); err != nil {
-				log.Printf("Could not pause (%s): %s", selected.Name, err)
+			if selected != nil {
+				pausePlayers([]mpris.Player{*selected})
 			}
 
 		case "play":
-			for _, p := range others {
-				if p.IsPlaying() {
-					if err := p.Pause(); err != nil {
-						log.Printf("Could not play (%s): %s", p.Name, err)
-					}
-				}
+			// The daemon reacts to the player starting by itself, and can restore ducked players later
+			if selected != nil && !daemon.IsRunning() {
+				pauseOthers(*selected, others, nil)
 			}
 			fallthrough
 		case "playOne":
-			if err := selected.Play(); err != nil {
-				log.Printf("Could not play (%s): %s", selected.Name, err)
+			if selected != nil {
+				playPlayers([]mpris.Player{*selected})
 			}
 
 		case "previous":
@@ -147,16 +156,132 @@ func main() {
 		case "controls":
 			if selected != nil {
 				model.Options = showControls(*selected, v)
-				model.Message = formatControlMessage(*selected)
+				model.Message = withConfigError(formatControlMessage(*selected))
 				model.Render()
 				currentView = v
+
+				updateProgress(&progress, renders, players, currentView)
+			}
+
+		case "bookmark":
+			if selected == nil {
+				continue
+			}
+			if _, err := addBookmark(*selected, ""); err != nil {
+				log.Printf("Could not add bookmark (%s): %s", selected.Name, err)
+				model.Message = withConfigError(html.EscapeString(err.Error()))
+				model.Render()
+				continue
+			}
+			progress.Stop()
+			currentView = rofi.Value{Cmd: "bookmarks"}
+			renderView(players, &model, currentView)
+
+		case "bookmarks":
+			progress.Stop()
+			currentView = v
+			renderView(players, &model, currentView)
+
+		case "openBookmark":
+			id, err := strconv.Atoi(v.Value)
+			if err == nil {
+				err = openBookmark(players, id)
+			}
+			if err != nil {
+				log.Printf("Could not open bookmark: %s", err)
+				model.Message = withConfigError(html.EscapeString(err.Error()))
+				model.Render()
+				continue
+			}
+			currentView = rofi.Value{}
+			renderView(players, &model, currentView)
+
+		case "removeBookmark":
+			id, err := strconv.Atoi(v.Value)
+			if err == nil {
+				err = removeBookmark(id)
 			}
+			if err != nil {
+				log.Printf("Could not remove bookmark: %s", err)
+			}
+			renderView(players, &model, currentView)
+
+		case "pauseAll":
+			pauseAll(players)
+			renderView(players, &model, currentView)
+
+		case "resumeAll":
+			resumeAll(players)
+			renderView(players, &model, currentView)
+
+		case "stopAll":
+			stopAll(players)
+			renderView(players, &model, currentView)
+
+		case "sleepTimer":
+			progress.Stop()
+			currentView = v
+			renderView(players, &model, currentView)
+
+		case "setSleep":
+			mode, d, err := parseSleepMode(v.Value)
+			if err == nil {
+				err = daemon.SetSleepTimer(mode, d)
+			}
+			if err != nil {
+				log.Printf("Could not set the sleep timer: %s", err)
+			}
+			currentView = rofi.Value{}
+			renderView(players, &model, currentView)
+			updateProgress(&progress, renders, players, currentView)
+
+		case "cancelSleep":
+			if err := daemon.CancelSleepTimer(); err != nil {
+				log.Printf("Could not cancel the sleep timer: %s", err)
+			}
+			currentView = rofi.Value{}
+			renderView(players, &model, currentView)
+			updateProgress(&progress, renders, players, currentView)
+
+		case "history", "stats":
+			progress.Stop()
+			currentView = v
+			renderView(players, &model, currentView)
+
+		case "openHistory":
+			if err := openHistory(players, v.Value); err != nil {
+				log.Printf("Could not open from history: %s", err)
+				model.Message = withConfigError(html.EscapeString(err.Error()))
+				model.Render()
+				continue
+			}
+			currentView = rofi.Value{}
+			renderView(players, &model, currentView)
+
+		case "alarms":
+			progress.Stop()
+			currentView = v
+			renderView(players, &model, currentView)
+
+		case "toggleAlarm", "removeAlarm":
+			id, err := strconv.Atoi(v.Value)
+			if err == nil && v.Cmd == "toggleAlarm" {
+				err = toggleAlarm(id)
+			} else if err == nil {
+				err = removeAlarm(id)
+			}
+			if err != nil {
+				log.Printf("Could not change alarm: %s", err)
+			}
+			renderView(players, &model, currentView)
 
 		case "showAll":
+			progress.Stop()
 			model.Options = showAllPlayers(players)
-			model.Message = " "
+			model.Message = withConfigError(sleepMessage())
 			model.Render()
 			currentView = rofi.Value{}
+			updateProgress(&progress, renders, players, currentView)
 
 		default:
 			return
@@ -165,22 +290,152 @@ func main() {
 }
 
 func formatControlMessage(p mpris.Player) string {
-	m := p.GetMetadata()
-	title := ""
+	return getConfig().formats.Message(p)
+}
+
+func withConfigError(message string) string {
+	err := getConfigError()
+	if err == nil {
+		return message
+	}
+
+	errMessage := fmt.Sprintf("<span color=\"red\">%s</span>", html.EscapeString(err.Error()))
+	if strings.TrimSpace(message) == "" {
+		return errMessage
+	}
+
+	return errMessage + "\r" + message
+}
+
+// renderQueue lets other goroutines ask the main loop to render again, since only the main loop may touch the model and the current view.
+// Requests made while one is already waiting are merged into it.
+type renderQueue struct {
+	players  chan struct{}
+	playback chan struct{}
+	all      chan struct{}
+}
+
+func newRenderQueue() renderQueue {
+	return renderQueue{players: make(chan struct{}, 1), playback: make(chan struct{}, 1), all: make(chan struct{}, 1)}
+}
+
+// Players renders the view again if it lists the players
+func (q renderQueue) Players() {
+	requestRender(q.players)
+}
+
+// Playback renders the view again if it lists the players, and starts or stops the progress
+func (q renderQueue) Playback() {
+	requestRender(q.playback)
+}
+
+// All renders the view again, whichever it is
+func (q renderQueue) All() {
+	requestRender(q.all)
+}
 
-	if m.Title != "" {
-		title += m.Title
-		if m.Artist != "" {
-			title = fmt.Sprintf("%s\r%s", html.EscapeString(title), html.EscapeString(m.Artist))
+func requestRender(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
+
+func renderView(players []mpris.Player, model *rofi.Model, view rofi.Value) {
+	model.Prompt = getConfig().Prompt
+
+	if view.Cmd == "controls" {
+		if selected, _ := separatePlayers(players, view.Value); selected != nil {
+			model.Options = showControls(*selected, view)
+			model.Message = withConfigError(formatControlMessage(*selected))
+			model.Render()
+			return
+		}
+	}
+
+	if view.Cmd == "history" {
+		model.Options = showHistory()
+		model.Message = withConfigError(" ")
+		model.Render()
+		return
+	}
+
+	if view.Cmd == "stats" {
+		now := time.Now()
+		s, err := computeStats(now.Add(-statsViewRange), now, statsViewTop)
+		if err != nil {
+			log.Printf("Could not load history: %s", err)
 		}
-		return title
+		model.Options = showStats(s)
+		model.Message = withConfigError(statsMessage(s))
+		model.Render()
+		return
 	}
 
-	if m.URL != "" {
-		return title + path.Base(m.URL)
+	if view.Cmd == "bookmarks" {
+		model.Options = showBookmarks()
+		model.Message = withConfigError("Add notes with: rofi-media bookmark note id text")
+		model.Render()
+		return
 	}
 
-	return p.Short
+	if view.Cmd == "alarms" {
+		model.Options = showAlarms()
+		model.Message = withConfigError(alarmsMessage())
+		model.Render()
+		return
+	}
+
+	if view.Cmd == "sleepTimer" {
+		s, _ := daemon.SleepTimer()
+		model.Options = showSleepTimer(s)
+		model.Message = withConfigError(sleepMessage())
+		model.Render()
+		return
+	}
+
+	model.Options = showAllPlayers(players)
+	model.Message = withConfigError(sleepMessage())
+	model.Render()
+}
+
+// updateProgress re-renders the view every tick while something in it moves:
+// the position of the player in the controls, or the sleep timer while the players are shown
+func updateProgress(progress *progressTicker, renders renderQueue, players []mpris.Player, view rofi.Value) {
+	switch view.Cmd {
+	case "controls":
+		if selected, _ := separatePlayers(players, view.Value); selected != nil && selected.IsPlaying() {
+			progress.Start(renders.All)
+			return
+		}
+	case "":
+		if isSleepCountingDown(players) {
+			progress.Start(renders.All)
+			return
+		}
+	}
+
+	progress.Stop()
+}
+
+// isSleepCountingDown reports whether the remaining time of the sleep timer changes.
+// At the end of a track or the queue it only does while a player plays.
+func isSleepCountingDown(players []mpris.Player) bool {
+	s, err := daemon.SleepTimer()
+	if err != nil || !s.IsActive() {
+		return false
+	}
+	if s.Mode == sleepModeDuration {
+		return true
+	}
+
+	for _, p := range players {
+		if p.IsPlaying() {
+			return true
+		}
+	}
+
+	return false
 }
 
 func separatePlayers(players []mpris.Player, name string) (*mpris.Player, []mpris.Player) {
@@ -205,14 +460,14 @@ func showControls(player mpris.Player, v rofi.Value) []rofi.Option {
 		opts = append(opts, rofi.Option{
 			Label: "Pause",
 			Cmds:  []string{"pause"},
-			Icon:  "player_pause",
+			Icon:  getConfig().Icons.Pause,
 			Value: v.Value,
 		})
 	} else {
 		opts = append(opts, rofi.Option{
 			Label: "Play",
 			Cmds:  []string{"playOne"},
-			Icon:  "player_play",
+			Icon:  getConfig().Icons.Play,
 			Value: v.Value,
 		})
 	}
@@ -221,34 +476,65 @@ func showControls(player mpris.Player, v rofi.Value) []rofi.Option {
 		rofi.Option{
 			Label: "Previous",
 			Cmds:  []string{"previous"},
-			Icon:  "player_rew",
+			Icon:  getConfig().Icons.Previous,
 			Value: v.Value,
 		},
 		rofi.Option{
 			Label: "Next",
 			Cmds:  []string{"next"},
-			Icon:  "player_fwd",
+			Icon:  getConfig().Icons.Next,
 			Value: v.Value,
 		},
-		rofi.Option{
-			Label: "Back",
-			Cmds:  []string{"showAll"},
-			Icon:  "back",
-			Value: "",
-		},
 	)
 
+	if m := player.GetMetadata(); player.CanSeek() && (m.URL != "" || m.ID != "" && m.Title != "") {
+		opts = append(opts, rofi.Option{
+			Label: "Bookmark position",
+			Cmds:  []string{"bookmark"},
+			Icon:  getConfig().Icons.Bookmark,
+			Value: v.Value,
+		})
+	}
+
+	opts = append(opts, rofi.Option{
+		Label: "Back",
+		Cmds:  []string{"showAll"},
+		Icon:  getConfig().Icons.Back,
+		Value: "",
+	})
+
 	return opts
 }
 
 func showAllPlayers(players []mpris.Player) []rofi.Option {
-	var opts []rofi.Option
+	c := getConfig()
+
+	var visible []mpris.Player
 	for _, player := range players {
-		m := player.GetMetadata()
+		if !c.isHidden(player) {
+			visible = append(visible, player)
+		}
+	}
+
+	sort.SliceStable(visible, func(a, b int) bool {
+		return c.playerRank(visible[a]) < c.playerRank(visible[b])
+	})
+
+	opts := showGroupActions(players)
+	opts = append(opts, showSleepAction()...)
+	opts = append(opts, showAlarmAction()...)
+	opts = append(opts, showHistoryAction()...)
+	opts = append(opts, showStatsAction()...)
+	opts = append(opts, showBookmarkAction()...)
+	for _, player := range visible {
+		title := formatTitle(player)
+		categoryColor := c.Colors.Category
+		if colors, ok := artColors(player); ok {
+			categoryColor = colors.Accent
+		}
 
-		title := formatTitle(m, player.Short, player.GetPlaybackStatus())
-		category := fmt.Sprintf("<span color=\"#C3C3C3\">%s</span>", player.Short)
-		icon := getIcon(player.Name, m.ID, m.ArtURL)
+		category := fmt.Sprintf("<span color=\"%s\">%s</span>", html.EscapeString(categoryColor), html.EscapeString(c.displayName(player)))
+		icon := getIcon(player)
 
 		if player.Name == title {
 			category = ""
@@ -275,134 +561,86 @@ func showAllPlayers(players []mpris.Player) []rofi.Option {
 	return opts
 }
 
-var localImageDir = path.Join(os.TempDir(), "/rofi-media")
-
-func getIconFromURL(name, url string) string {
-	// Needs any extension to work. doesnt matter which
-	p := path.Join(localImageDir, strings.ReplaceAll(strings.TrimPrefix(name, "/"), "/", "-")+".img")
-
-	if fs.ValidPath(p) {
-		return p
-	}
-
-	ctx := context.Background()
+func getIconFromURL(url string) string {
+	return artFetcher.Get(url)
+}
 
-	ctx, cancel := context.WithTimeout(ctx, time.Duration(time.Second))
-	defer cancel()
+func configureArt(c ArtConfig) {
+	artCache.Configure(c.Dir, c.MaxSizeMB<<20, c.MaxAge)
+	artFetcher.SetIconSize(c.IconSize)
+}
 
-	var reader io.ReadCloser
+func formatTitle(p mpris.Player) string {
+	return getConfig().formats.Title(p)
+}
 
-	http.NewRequestWithContext(ctx, http.MethodGet, url, reader)
-	resp, err := http.Get(url)
-	if err != nil {
-		return ""
+func getIcon(p mpris.Player) string {
+	m := p.GetMetadata()
 
+	if m.ArtURL != "" {
+		icon := getIconFromURL(m.ArtURL)
+		if icon != "" {
+			return icon
+		}
+	} else if m.URL != "" {
+		icon := artFetcher.GetFromMedia(m.URL)
+		if icon != "" {
+			return icon
+		}
 	}
-	defer resp.Body.Close()
 
-	// Do we need other 2xx codes here?
-	if resp.StatusCode != http.StatusOK && !strings.Contains(resp.Header.Get("Content-Type"), "image/") {
-		log.Printf("Not a valid icon: %s\n", err)
-		return ""
-	}
+	return getPlayerIcon(p)
+}
 
-	if err := os.MkdirAll(localImageDir, os.ModePerm); err != nil {
-		log.Printf("Error while creating path: %s\n", err)
-		return ""
+func artColors(p mpris.Player) (art.Colors, bool) {
+	if !getConfig().Art.Colors {
+		return art.Colors{}, false
 	}
 
-	f, err := os.OpenFile(p, os.O_RDWR|os.O_CREATE, 0666)
-	if err != nil {
-		log.Printf("Error while opening icon file: %s\n", err)
-		return ""
+	// Only art has colours, not icons from the icon theme
+	icon := getIcon(p)
+	if !filepath.IsAbs(icon) || icon == getPlayerIcon(p) {
+		return art.Colors{}, false
 	}
-	defer f.Close()
 
-	if _, err := io.Copy(f, resp.Body); err != nil {
-		log.Printf("Error while writing icon into file: %s\n", err)
-	}
-
-	return p
+	return artFetcher.Colors(icon)
 }
 
-func formatTitle(m mpris.Media, shortname string, playbackStatus mpris.PlaybackStatus) string {
-	title := " "
-	if playbackStatus == mpris.PlaybackStatusPlaying {
-		title = " "
-	} else if playbackStatus == mpris.PlaybackStatusStopped {
-		title = " "
-	}
-	if m.Title != "" {
-		title += m.Title
-		if m.Artist != "" {
-			title = fmt.Sprintf("%s\r%s", html.EscapeString(title), html.EscapeString(m.Artist))
-		}
-		return title
+func getPlayerIcon(p mpris.Player) string {
+	if icon := getConfig().playerIcon(p.Name); icon != "" {
+		return icon
 	}
 
-	if m.URL != "" {
-		return title + path.Base(m.URL)
+	if e, ok := desktop.Lookup(p.DesktopEntry); ok {
+		return e.Icon
 	}
 
-	return title + shortname
+	return ""
 }
 
-func getIcon(name, id, url string) string {
-	if url != "" {
-		icon := getIconFromURL(id, url)
-		if icon != "" {
-			return icon
-		}
-	}
-
-	switch {
-	case strings.Contains(name, "chromium"):
-		return "google-chrome"
-	case strings.Contains(name, "spotify"):
-		return "spotify"
-	case strings.Contains(name, "vlc"):
-		return "vlc"
-	default:
-		return ""
+func onDisconnect(renders renderQueue) func(name string) {
+	return func(name string) {
+		renders.Players()
 	}
 }
 
-func onDisconnect(players *[]mpris.Player, model *rofi.Model, view *rofi.Value) func(name string) {
-	return func(name string) {
-		for i, player := range *players {
-			if player.Name == name {
-				*players = append((*players)[:i], ((*players)[i+1:])...)
-				switch view.Cmd {
-				case "controls":
-					selected, _ := separatePlayers(*players, view.Value)
-					if selected.Name == name {
-						model.Options = showAllPlayers(*players)
-						model.Render()
-					}
-
-					model.Options = showControls(*selected, *view)
-					model.Render()
-				default:
-					model.Options = showAllPlayers(*players)
-					model.Render()
-				}
-			}
+func onPropertyChange(renders renderQueue) func(name string, changedProperties []string) {
+	return func(name string, changedProperties []string) {
+		if hasProperty(changedProperties, "PlaybackStatus") {
+			renders.Playback()
+			return
 		}
+
+		renders.Players()
 	}
 }
 
-func onPropertyChange(players *[]mpris.Player, model *rofi.Model, view *rofi.Value) func(name string, changedProperties []string) {
-	return func(name string, changedProperties []string) {
-		switch view.Cmd {
-		case "controls":
-			selected, _ := separatePlayers(*players, view.Value)
-			if selected != nil && selected.Name == name {
-				model.Options = showControls(*selected, *view)
-				model.Render()
-			}
-		default:
-			model.Options = showAllPlayers(*players)
-			model.Render()
+func hasProperty(changedProperties []string, prop string) bool {
+	for _, p := range changedProperties {
+		if p == prop {
+			return true
 		}
 	}
+
+	return false
 }
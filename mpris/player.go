@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log"
 	"regexp"
+	"strings"
 	"sync"
 	"time"
 
@@ -18,6 +19,9 @@ type Player struct {
 	Short       string
 	ownerID     string
 
+	Identity     string
+	DesktopEntry string
+
 	properties  *properties
 	isConnected bool
 }
@@ -36,9 +40,11 @@ const (
 
 	memberNameOwnerChanged      = "NameOwnerChanged"
 	memberNamePropertiesChanged = "PropertiesChanged"
+	memberNameSeeked            = "Seeked"
 
 	signalNamePropertiesChanged = interfacePathDBusProperties + "." + memberNamePropertiesChanged
 	signalNameOwnerChanged      = interfacePathDBus + "." + memberNameOwnerChanged
+	signalNameSeeked            = interfacePathMprisMediaPlayer2Player + "." + memberNameSeeked
 )
 
 var destinationRegexp *regexp.Regexp
@@ -98,6 +104,17 @@ func (p *Player) Register(c *dbus.Conn, onDisconnect func(name string), onProper
 		log.Printf("mpris.listener: Could not listen on disconnect changes for %s: %s", p.destination, err)
 	}
 
+	signalSeekedOpts := []dbus.MatchOption{
+		dbus.WithMatchObjectPath(objectPathMpris),
+		dbus.WithMatchInterface(interfacePathMprisMediaPlayer2Player),
+		dbus.WithMatchMember(memberNameSeeked),
+		dbus.WithMatchSender(p.ownerID),
+	}
+	err = c.AddMatchSignal(signalSeekedOpts...)
+	if err != nil {
+		log.Printf("mpris.listener: Could not listen on seeks for %s: %s", p.destination, err)
+	}
+
 	signalCh := make(chan *dbus.Signal)
 	c.Signal(signalCh)
 
@@ -108,6 +125,7 @@ func (p *Player) Register(c *dbus.Conn, onDisconnect func(name string), onProper
 			onDisconnect(p.Name)
 			conn.RemoveMatchSignal(signalNameOwnerChangedOpts...)
 			conn.RemoveMatchSignal(signalPropertyChangeOpts...)
+			conn.RemoveMatchSignal(signalSeekedOpts...)
 		}()
 
 	Loop:
@@ -132,10 +150,25 @@ func (p *Player) Register(c *dbus.Conn, onDisconnect func(name string), onProper
 					}
 
 					if cl := p.UpdateProperties(varMap); len(cl) > 0 {
+						// Players don't signal position changes, so it's refreshed on anything that might move it
+						if hasChanged(cl, "PlaybackStatus", "Metadata", "Rate") {
+							if err := p.SyncPosition(); err != nil {
+								log.Printf("mpris.Register: Could not sync position for %s: %s", p.destination, err)
+							}
+						}
 						onPropertyChange(p.Name, cl)
 					}
 				}
 
+			case signalNameSeeked:
+				if msg.Sender != p.ownerID || len(msg.Body) != 1 {
+					continue
+				}
+				if v, ok := msg.Body[0].(int64); ok {
+					p.setPosition(time.Duration(v) * time.Microsecond)
+					onPropertyChange(p.Name, []string{"Position"})
+				}
+
 			case signalNameOwnerChanged:
 				if name, ok := msg.Body[0].(string); ok && name == p.Name {
 					if ownerID, ok := msg.Body[1].(string); ok && ownerID != "" {
@@ -152,10 +185,28 @@ type properties struct {
 	PlaybackStatus PlaybackStatus
 	LoopStatus     LoopStatus
 	Shuffle        bool
+	Rate           float64
 
 	Media Media
 
-	sync.Mutex
+	// Last known position and when it was known
+	position   time.Duration
+	positionAt time.Time
+
+	// Signals update the properties while any goroutine reads them
+	sync.RWMutex
+}
+
+func hasChanged(changeList []string, keys ...string) bool {
+	for _, c := range changeList {
+		for _, k := range keys {
+			if c == k {
+				return true
+			}
+		}
+	}
+
+	return false
 }
 
 func NewPlayer(conn *dbus.Conn, dest string, ownerID string, onDisconnect func(name string), onPropertyChange func(name string, changedProps []string)) (Player, error) {
@@ -187,12 +238,34 @@ func NewPlayer(conn *dbus.Conn, dest string, ownerID string, onDisconnect func(n
 
 	player.UpdateProperties(rawProps)
 
+	player.Identity, player.DesktopEntry = GetIdentity(conn, dest)
+
 	player.Register(conn, onDisconnect, onPropertyChange)
 
 	player.isConnected = true
 	return player, nil
 }
 
+// GetIdentity looks up the Identity and DesktopEntry root properties without creating a player.
+// Properties that the player doesn't provide are returned empty.
+func GetIdentity(conn *dbus.Conn, dest string) (identity string, desktopEntry string) {
+	o := conn.Object(dest, objectPathMpris)
+
+	if prop, err := o.GetProperty(interfacePathMprisMediaPlayer2 + ".Identity"); err == nil {
+		if v, ok := prop.Value().(string); ok {
+			identity = v
+		}
+	}
+
+	if prop, err := o.GetProperty(interfacePathMprisMediaPlayer2 + ".DesktopEntry"); err == nil {
+		if v, ok := prop.Value().(string); ok {
+			desktopEntry = v
+		}
+	}
+
+	return
+}
+
 func (p *Player) UpdateProperties(props map[string]dbus.Variant) (changeList []string) {
 	if p.properties == nil {
 		p.properties = &properties{}
@@ -213,11 +286,28 @@ func (p *Player) UpdateProperties(props map[string]dbus.Variant) (changeList []s
 			if v, ok := val.Value().(string); ok {
 				s := PlaybackStatus(v)
 				if s.IsValid() && p.properties.PlaybackStatus != s {
+					// Keep the extrapolated position from drifting while paused
+					p.properties.position = p.extrapolatePosition()
+					p.properties.positionAt = time.Now()
 					p.properties.PlaybackStatus = s
 					changeList = append(changeList, key)
 				}
 			}
 
+		case "Rate":
+			if v, ok := val.Value().(float64); ok && p.properties.Rate != v {
+				p.properties.position = p.extrapolatePosition()
+				p.properties.positionAt = time.Now()
+				p.properties.Rate = v
+				changeList = append(changeList, key)
+			}
+
+		case "Position":
+			if v, ok := val.Value().(int64); ok {
+				p.properties.position = time.Duration(v) * time.Microsecond
+				p.properties.positionAt = time.Now()
+			}
+
 		case "LoopStatus":
 			if v, ok := val.Value().(string); ok {
 				s := LoopStatus(v)
@@ -327,7 +417,7 @@ func (p Player) CanPlay() bool {
 }
 
 func (p Player) Play() error {
-	if p.properties.PlaybackStatus == PlaybackStatusPlaying {
+	if p.GetPlaybackStatus() == PlaybackStatusPlaying {
 		return nil
 	}
 	if !p.CanPlay() {
@@ -357,7 +447,7 @@ func (p Player) CanControl() bool {
 }
 
 func (p Player) Stop() error {
-	if p.properties.PlaybackStatus == PlaybackStatusStopped {
+	if p.GetPlaybackStatus() == PlaybackStatusStopped {
 		return nil
 	}
 
@@ -388,7 +478,7 @@ func (p Player) CanPause() bool {
 }
 
 func (p Player) Pause() error {
-	if p.properties.PlaybackStatus == PlaybackStatusPaused {
+	if p.GetPlaybackStatus() == PlaybackStatusPaused {
 		return nil
 	}
 
@@ -500,14 +590,132 @@ func (p Player) Seek(seconds int) error {
 	return nil
 }
 
+func (p Player) GetVolume() (float64, error) {
+	prop, err := p.getPlayerProp("Volume")
+	if err != nil {
+		return 0, fmt.Errorf("mpris.GetVolume: %w", err)
+	}
+
+	v, ok := prop.Value().(float64)
+	if !ok {
+		return 0, fmt.Errorf("mpris.GetVolume: %s", ErrUnsupported)
+	}
+
+	return v, nil
+}
+
+// CanSetVolume reports whether the player can be controlled and exposes its volume.
+// Players are still allowed to ignore the new volume.
+func (p Player) CanSetVolume() bool {
+	if !p.CanControl() {
+		return false
+	}
+
+	_, err := p.GetVolume()
+	return err == nil
+}
+
+func (p Player) SetVolume(volume float64) error {
+	if !p.CanControl() {
+		return fmt.Errorf("mpris.SetVolume: %s", ErrUnsupported)
+	}
+
+	if volume < 0 {
+		volume = 0
+	}
+
+	err := p.obj.SetProperty(interfacePathMprisMediaPlayer2Player+".Volume", dbus.MakeVariant(volume))
+	if err != nil {
+		return fmt.Errorf("mpris.SetVolume: %w", err)
+	}
+
+	return nil
+}
+
 func (p Player) GetMetadata() Media {
+	p.properties.RLock()
+	defer p.properties.RUnlock()
+
 	return p.properties.Media
 }
 
+func (p Player) GetPosition() (time.Duration, error) {
+	prop, err := p.getPlayerProp("Position")
+	if err != nil {
+		return 0, fmt.Errorf("mpris.GetPosition: %w", err)
+	}
+
+	v, ok := prop.Value().(int64)
+	if !ok {
+		return 0, fmt.Errorf("mpris.GetPosition: %s", ErrUnsupported)
+	}
+
+	return time.Duration(v) * time.Microsecond, nil
+}
+
+// SyncPosition refreshes the tracked position from the player
+func (p *Player) SyncPosition() error {
+	pos, err := p.GetPosition()
+	if err != nil {
+		return fmt.Errorf("mpris.SyncPosition: %w", err)
+	}
+
+	p.setPosition(pos)
+	return nil
+}
+
+func (p *Player) setPosition(pos time.Duration) {
+	p.properties.Lock()
+	defer p.properties.Unlock()
+
+	p.properties.position = pos
+	p.properties.positionAt = time.Now()
+}
+
+// Position returns the tracked position, extrapolated from when it was last known while playing.
+// Use GetPosition to ask the player instead.
+func (p Player) Position() time.Duration {
+	p.properties.RLock()
+	defer p.properties.RUnlock()
+
+	return p.extrapolatePosition()
+}
+
+// extrapolatePosition expects properties to be locked
+func (p Player) extrapolatePosition() time.Duration {
+	pos := p.properties.position
+	if p.properties.PlaybackStatus == PlaybackStatusPlaying && !p.properties.positionAt.IsZero() {
+		rate := p.properties.Rate
+		if rate <= 0 {
+			rate = 1
+		}
+		pos += time.Duration(float64(time.Since(p.properties.positionAt)) * rate)
+	}
+
+	if length := p.properties.Media.Length; length > 0 && pos > length {
+		pos = length
+	}
+	if pos < 0 {
+		pos = 0
+	}
+
+	return pos
+}
+
 func (p Player) GetPlaybackStatus() PlaybackStatus {
+	p.properties.RLock()
+	defer p.properties.RUnlock()
+
 	return p.properties.PlaybackStatus
 }
 
+func (p Player) GetLoopStatus() LoopStatus {
+	p.properties.RLock()
+	defer p.properties.RUnlock()
+
+	return p.properties.LoopStatus
+}
+
 func (p Player) IsPlaying() bool {
 	s := p.GetPlaybackStatus()
 
@@ -515,9 +723,98 @@ func (p Player) IsPlaying() bool {
 }
 
 func (p *Player) SetPosition(trackID dbus.ObjectPath, microseconds int64) error {
-	return ErrNotImplemented
+	if !p.CanSeek() {
+		return fmt.Errorf("mpris.SetPosition: %s", ErrUnsupported)
+	}
+	if !trackID.IsValid() {
+		return fmt.Errorf("mpris.SetPosition: invalid track id %q", trackID)
+	}
+
+	err := p.makePlayerCall("SetPosition", trackID, microseconds)
+
+	if err != nil {
+		return fmt.Errorf("mpris.SetPosition: %w", err)
+	}
+
+	return nil
+}
+
+// SeekTo moves to pos in the current track.
+// Players that don't give their tracks an id are seeked from their current position instead.
+func (p *Player) SeekTo(pos time.Duration) error {
+	m, err := p.FetchMetadata()
+	if err != nil {
+		return fmt.Errorf("mpris.SeekTo: %w", err)
+	}
+
+	if trackID := dbus.ObjectPath(m.ID); trackID.IsValid() && trackID != "/org/mpris/MediaPlayer2/TrackList/NoTrack" {
+		return p.SetPosition(trackID, pos.Microseconds())
+	}
+
+	if !p.CanSeek() {
+		return fmt.Errorf("mpris.SeekTo: %s", ErrUnsupported)
+	}
+
+	current, err := p.GetPosition()
+	if err != nil {
+		return fmt.Errorf("mpris.SeekTo: %w", err)
+	}
+
+	if err := p.makePlayerCall("Seek", (pos - current).Microseconds()); err != nil {
+		return fmt.Errorf("mpris.SeekTo: %w", err)
+	}
+
+	return nil
+}
+
+// FetchMetadata asks the player for its current track, instead of waiting for it to signal the change
+func (p Player) FetchMetadata() (Media, error) {
+	var m Media
+
+	prop, err := p.getPlayerProp("Metadata")
+	if err != nil {
+		return m, fmt.Errorf("mpris.FetchMetadata: %w", err)
+	}
+
+	if err := decodeMetadata(prop.Value(), &m); err != nil {
+		return m, fmt.Errorf("mpris.FetchMetadata: %w", err)
+	}
+
+	return m, nil
+}
+
+func (p Player) SupportedUriSchemes() []string {
+	prop, err := p.getRootProp("SupportedUriSchemes")
+	if err != nil {
+		return nil
+	}
+
+	v, _ := prop.Value().([]string)
+	return v
+}
+
+// CanOpenUri reports whether the scheme of uri is one of the SupportedUriSchemes
+func (p Player) CanOpenUri(uri string) bool {
+	scheme, _, ok := strings.Cut(uri, ":")
+	if !ok {
+		return false
+	}
+
+	for _, s := range p.SupportedUriSchemes() {
+		if strings.EqualFold(s, scheme) {
+			return true
+		}
+	}
+
+	return false
 }
 
 func (p *Player) OpenUri(uri string) error {
-	return ErrNotImplemented
+	err := p.makePlayerCall("OpenUri", uri)
+
+	if err != nil {
+		return fmt.Errorf("mpris.OpenUri: %w", err)
+	}
+
+	return nil
 }
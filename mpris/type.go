@@ -29,10 +29,15 @@ func decodeMetadata(metadata any, m *Media) error {
 		return fmt.Errorf("mpris.decodeMetadata: metadata is not a valid structure")
 	}
 
+	// Metadata is always sent whole, and fields missing from the new track shouldn't linger
+	*m = Media{}
+
 	for key, val := range metadataMap {
 		switch key {
 		case "mpris:trackid":
-			if v, ok := val.Value().(string); ok {
+			if v, ok := val.Value().(dbus.ObjectPath); ok {
+				m.ID = string(v)
+			} else if v, ok := val.Value().(string); ok {
 				m.ID = v
 			}
 		case "mpris:length":
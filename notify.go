@@ -0,0 +1,186 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"log"
+	"path"
+	"sync"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/ingentingalls/rofi-media/mpris"
+)
+
+const notificationsDest = "org.freedesktop.Notifications"
+const notificationsInterface = "org.freedesktop.Notifications"
+const notificationsObjectPath = "/org/freedesktop/Notifications"
+const memberActionInvoked = "ActionInvoked"
+const signalActionInvoked = notificationsInterface + "." + memberActionInvoked
+
+const notificationAppName = "rofi-media"
+const notificationTimeout = int32(5000)
+
+// notifier shows a notification when a track starts. It runs in the daemon, so it works while rofi is closed.
+type notifier struct {
+	obj dbus.BusObject
+
+	// id is the notification on screen, and player is the one it's about
+	id     uint32
+	player string
+	// latest is the player and track that was notified last, while its art may still be fetched
+	latest string
+
+	sync.Mutex
+}
+
+func newNotifier(conn *dbus.Conn, onAction func(player, action string)) (*notifier, error) {
+	n := &notifier{
+		obj: conn.Object(notificationsDest, notificationsObjectPath),
+	}
+
+	err := conn.AddMatchSignal(
+		dbus.WithMatchObjectPath(notificationsObjectPath),
+		dbus.WithMatchInterface(notificationsInterface),
+		dbus.WithMatchMember(memberActionInvoked),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("notifier: could not listen on actions: %w", err)
+	}
+
+	signalCh := make(chan *dbus.Signal)
+	conn.Signal(signalCh)
+
+	go func() {
+		for msg := range signalCh {
+			if msg.Name != signalActionInvoked {
+				continue
+			}
+			if len(msg.Body) != 2 {
+				log.Printf("notifier: Object received didnt have enough args for %s. Wanted %d, got %d", signalActionInvoked, 2, len(msg.Body))
+				continue
+			}
+
+			id, ok := msg.Body[0].(uint32)
+			if !ok {
+				continue
+			}
+			action, ok := msg.Body[1].(string)
+			if !ok {
+				continue
+			}
+
+			n.Lock()
+			player := n.player
+			isOurs := id == n.id
+			n.Unlock()
+
+			if isOurs {
+				onAction(player, action)
+			}
+		}
+	}()
+
+	return n, nil
+}
+
+func (n *notifier) Notify(p mpris.Player) error {
+	m := p.GetMetadata()
+
+	track := p.Name + "\x00" + m.ID + "\x00" + m.Title + "\x00" + m.URL
+	if m.Title == "" && m.URL == "" {
+		return nil
+	}
+
+	n.Lock()
+	if n.latest == track {
+		n.Unlock()
+		return nil
+	}
+	n.latest = track
+	n.Unlock()
+
+	summary := m.Title
+	if summary == "" {
+		summary = path.Base(m.URL)
+	}
+
+	body := html.EscapeString(m.Artist)
+	if m.Album != "" {
+		if body != "" {
+			body += "\n"
+		}
+		body += "<i>" + html.EscapeString(m.Album) + "</i>"
+	}
+
+	hints := map[string]dbus.Variant{}
+	img := ""
+	if m.ArtURL != "" {
+		img = artFetcher.Wait(m.ArtURL)
+	} else if m.URL != "" {
+		img = artFetcher.WaitFromMedia(m.URL)
+	}
+	if img != "" {
+		hints["image-path"] = dbus.MakeVariant(img)
+	}
+
+	n.Lock()
+	defer n.Unlock()
+
+	// Another track started while the art was fetched
+	if n.latest != track {
+		return nil
+	}
+
+	actions := []string{
+		"previous", "Previous",
+		"pause", "Pause",
+		"next", "Next",
+	}
+
+	var id uint32
+	call := n.obj.Call(notificationsInterface+".Notify", dbus.Flags(0),
+		notificationAppName,
+		n.id,
+		getPlayerIcon(p),
+		summary,
+		body,
+		actions,
+		hints,
+		notificationTimeout,
+	)
+	if err := call.Store(&id); err != nil {
+		// Tried again on the next change
+		n.latest = ""
+		return fmt.Errorf("notifier.Notify: %w", err)
+	}
+
+	n.id = id
+	n.player = p.Name
+
+	return nil
+}
+
+func onNotificationAction(players *playerList) func(name, action string) {
+	return func(name, action string) {
+		selected, _ := separatePlayers(players.All(), name)
+		if selected == nil {
+			return
+		}
+
+		var err error
+		switch action {
+		case "previous":
+			err = selected.Previous()
+		case "pause":
+			err = pausePlayer(*selected)
+		case "next":
+			err = selected.Next()
+		default:
+			return
+		}
+
+		if err != nil {
+			log.Printf("Could not %s from notification (%s): %s", action, name, err)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/ingentingalls/rofi-media/mpris"
+)
+
+// playerList holds the players on the bus. The bus watcher adds and removes them while
+// rofi, the daemon and its timers read them, so it's only used through its methods.
+type playerList struct {
+	players []mpris.Player
+
+	mu sync.RWMutex
+}
+
+// All returns a copy of the list. The players still share their properties with the list
+func (l *playerList) All() []mpris.Player {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return append([]mpris.Player(nil), l.players...)
+}
+
+func (l *playerList) has(name string) bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	for _, p := range l.players {
+		if p.Name == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// add appends p unless a player with the same bus name is already listed
+func (l *playerList) add(p mpris.Player) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, existing := range l.players {
+		if existing.Name == p.Name {
+			return false
+		}
+	}
+
+	l.players = append(l.players, p)
+	return true
+}
+
+func (l *playerList) remove(name string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for i, p := range l.players {
+		if p.Name == name {
+			l.players = append(l.players[:i:i], l.players[i+1:]...)
+			return
+		}
+	}
+}
+
+// watchPlayers adds the players on the bus to players, and keeps adding players as they appear.
+// Players are removed from the list before onDisconnect is called.
+// The returned function looks for players that were skipped earlier, e.g. hidden players after a config reload.
+func watchPlayers(conn *dbus.Conn, players *playerList, onDisconnect func(name string), onPropertyChange func(name string, changedProperties []string)) (func(), error) {
+	disconnect := func(name string) {
+		players.remove(name)
+		onDisconnect(name)
+	}
+
+	// The bus watcher and rediscover can find the same player at the same time,
+	// and every registered player listens on the bus until it disconnects
+	var addMu sync.Mutex
+	addPlayer := func(name, ownerID string) {
+		addMu.Lock()
+		defer addMu.Unlock()
+
+		if players.has(name) {
+			return
+		}
+
+		identity, desktopEntry := mpris.GetIdentity(conn, name)
+		if getConfig().ruleFor(name, identity, desktopEntry).Hide {
+			log.Printf("Ignoring hidden player: %s\n", name)
+			return
+		}
+
+		player, err := mpris.NewPlayer(conn, name, ownerID, disconnect, onPropertyChange)
+		if err != nil {
+			log.Printf("Could not create a new player from %s: %s", name, err)
+			return
+		}
+		players.add(player)
+	}
+
+	conn.AddMatchSignal(
+		dbus.WithMatchObjectPath(dbusObjectPath),
+		dbus.WithMatchInterface(dbusInterface),
+		dbus.WithMatchMember(memberNameOwnerChanged),
+	)
+
+	signalCh := make(chan *dbus.Signal)
+	conn.Signal(signalCh)
+
+	go func() {
+		for {
+			msg, ok := <-signalCh
+			if !ok {
+				log.Println("not ok")
+				break
+			}
+			if msg.Name != signalNameOwnerChanged {
+				continue
+			}
+			if len(msg.Body) != 3 {
+				log.Printf("main: Object received didnt have enough args for %s. Wanted %d, got %d", signalNameOwnerChanged, 3, len(msg.Body))
+				continue
+			}
+			if name, ok := msg.Body[0].(string); ok && mpris.HasValidDestinationName(name) {
+				if ownerID, ok := msg.Body[2].(string); ok && ownerID != "" {
+					log.Printf("Discovered new player: %s\n", name)
+					addPlayer(name, ownerID)
+				}
+			}
+		}
+	}()
+
+	obj := conn.Object(dbusDest, dbusObjectPath)
+	if err := discoverPlayers(obj, players.All(), addPlayer); err != nil {
+		return nil, err
+	}
+
+	rediscover := func() {
+		if err := discoverPlayers(obj, players.All(), addPlayer); err != nil {
+			log.Printf("Could not rediscover players: %s", err)
+		}
+	}
+
+	return rediscover, nil
+}
+
+func discoverPlayers(obj dbus.BusObject, known []mpris.Player, addPlayer func(name, ownerID string)) error {
+	resp := obj.Call("org.freedesktop.DBus.ListNames", dbus.Flags(0))
+	if resp.Err != nil {
+		return fmt.Errorf("listnames: %w", resp.Err)
+	}
+
+	var names []string
+	if err := resp.Store(&names); err != nil {
+		return fmt.Errorf("could not get names: %w", err)
+	}
+
+	for _, name := range names {
+		if !mpris.HasValidDestinationName(name) {
+			continue
+		}
+		if selected, _ := separatePlayers(known, name); selected != nil {
+			continue
+		}
+
+		var ownerID string
+		ownerResp := obj.Call("org.freedesktop.DBus.GetNameOwner", 0, name)
+		if err := ownerResp.Store(&ownerID); err != nil {
+			log.Printf("Couldn't find owner for %s: %s", name, err)
+		}
+		addPlayer(name, ownerID)
+	}
+
+	return nil
+}
+
+// findPlayer looks a player up by its bus name, short name or Identity, as written by users
+func findPlayer(players []mpris.Player, name string) *mpris.Player {
+	for _, p := range players {
+		if p.Name == name || p.Short == name || strings.EqualFold(p.Identity, name) {
+			p := p
+			return &p
+		}
+	}
+
+	return nil
+}
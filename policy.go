@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/ingentingalls/rofi-media/mpris"
+)
+
+// PolicyConfig decides what happens to the other players when a matching player starts playing
+type PolicyConfig struct {
+	PlayerMatch
+
+	// NeverPause keeps the player playing when another player starts
+	NeverPause bool `toml:"never_pause"`
+	// KeepOthers lets the player start without pausing anything
+	KeepOthers bool `toml:"keep_others"`
+	// OnlyPause limits which players are paused when this player starts.
+	// Globs are matched against the bus name, short name, Identity and DesktopEntry
+	OnlyPause []string `toml:"only_pause"`
+
+	// Priority decides which players are ducked. Players are only ducked by players with a higher priority
+	Priority int `toml:"priority"`
+	// Duck lowers the volume of the player instead of pausing it. Players without volume control are paused
+	Duck bool `toml:"duck"`
+	// DuckVolume is the volume of a ducked player, between 0 and 1. Defaults to 0.2
+	DuckVolume float64 `toml:"duck_volume"`
+
+	onlyPause []func(s string) bool
+}
+
+type playerPolicy struct {
+	NeverPause bool
+	KeepOthers bool
+	Priority   int
+	Duck       bool
+	DuckVolume float64
+
+	onlyPause []func(s string) bool
+}
+
+func (pc *PolicyConfig) compile() error {
+	if err := pc.PlayerMatch.compile(); err != nil {
+		return err
+	}
+
+	if pc.DuckVolume < 0 || pc.DuckVolume > 1 {
+		return fmt.Errorf("duck_volume has to be between 0 and 1")
+	}
+
+	pc.onlyPause = nil
+	for _, pattern := range pc.OnlyPause {
+		m, err := compilePattern(pattern, false)
+		if err != nil {
+			return err
+		}
+		pc.onlyPause = append(pc.onlyPause, m)
+	}
+
+	return nil
+}
+
+func compilePolicies(policies []PolicyConfig) error {
+	for i := range policies {
+		if err := policies[i].compile(); err != nil {
+			return fmt.Errorf("policy %d: %w", i+1, err)
+		}
+	}
+
+	return nil
+}
+
+// policyFor merges every matching policy
+func (c *Config) policyFor(p mpris.Player) playerPolicy {
+	var pp playerPolicy
+	var hasPriority bool
+	for _, pc := range c.Policies {
+		if !pc.matches(p.Name, p.Identity, p.DesktopEntry) {
+			continue
+		}
+
+		pp.NeverPause = pp.NeverPause || pc.NeverPause
+		pp.KeepOthers = pp.KeepOthers || pc.KeepOthers
+		pp.Duck = pp.Duck || pc.Duck
+		if !hasPriority || pc.Priority > pp.Priority {
+			pp.Priority = pc.Priority
+			hasPriority = true
+		}
+		if pp.DuckVolume == 0 {
+			pp.DuckVolume = pc.DuckVolume
+		}
+		pp.onlyPause = append(pp.onlyPause, pc.onlyPause...)
+	}
+
+	if pp.DuckVolume == 0 {
+		pp.DuckVolume = defaultDuckVolume
+	}
+
+	return pp
+}
+
+func (pp playerPolicy) pauses(p mpris.Player) bool {
+	if len(pp.onlyPause) == 0 {
+		return true
+	}
+
+	short := strings.TrimPrefix(p.Name, "org.mpris.MediaPlayer2.")
+	for _, m := range pp.onlyPause {
+		for _, s := range []string{p.Name, short, p.Identity, p.DesktopEntry} {
+			if s != "" && m(s) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// pauseOthers pauses the other players that are playing, unless a policy says otherwise.
+// Lower priority players are ducked instead when they allow it and d is set,
+// since someone has to restore their volume afterwards.
+func pauseOthers(selected mpris.Player, others []mpris.Player, d *ducker) {
+	c := getConfig()
+
+	sp := c.policyFor(selected)
+	if sp.KeepOthers {
+		return
+	}
+
+	var paused []mpris.Player
+	for _, p := range others {
+		if p.Name == selected.Name || !p.IsPlaying() {
+			continue
+		}
+
+		op := c.policyFor(p)
+		if op.NeverPause || !sp.pauses(p) {
+			continue
+		}
+
+		if d != nil && op.Duck && sp.Priority > op.Priority {
+			err := d.Duck(p, selected.Name, op.DuckVolume)
+			if err == nil {
+				continue
+			}
+			log.Printf("Could not duck (%s), pausing instead: %s", p.Name, err)
+		}
+
+		paused = append(paused, p)
+	}
+
+	pausePlayers(paused)
+}
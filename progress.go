@@ -0,0 +1,51 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+const progressInterval = time.Second
+
+// progressTicker re-renders the controls view so the progress keeps moving
+type progressTicker struct {
+	stop chan struct{}
+
+	sync.Mutex
+}
+
+// Start calls tick every interval until Stop is called. Starting it again restarts it.
+func (t *progressTicker) Start(tick func()) {
+	t.Lock()
+	defer t.Unlock()
+
+	if t.stop != nil {
+		close(t.stop)
+	}
+	stop := make(chan struct{})
+	t.stop = stop
+
+	go func() {
+		ticker := time.NewTicker(progressInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				tick()
+			}
+		}
+	}()
+}
+
+func (t *progressTicker) Stop() {
+	t.Lock()
+	defer t.Unlock()
+
+	if t.stop != nil {
+		close(t.stop)
+		t.stop = nil
+	}
+}
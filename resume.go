@@ -0,0 +1,208 @@
+package main
+
+import (
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ingentingalls/rofi-media/mpris"
+)
+
+const resumeStateFile = "resume.json"
+
+// Only the most recently left tracks are remembered
+const maxResumePositions = 100
+
+// Positions this close to the start are where the track starts anyway,
+// and this close to the end the track was finished
+const resumeMargin = 30 * time.Second
+
+type resumePosition struct {
+	Position time.Duration `json:"position"`
+	Length   time.Duration `json:"length"`
+	Saved    time.Time     `json:"saved"`
+}
+
+// resumeState is keyed by the url of the track
+type resumeState struct {
+	Positions map[string]resumePosition `json:"positions"`
+}
+
+// resumeTrack is the last known position of what a player is playing
+type resumeTrack struct {
+	media    mpris.Media
+	position time.Duration
+	at       time.Time
+	playing  bool
+
+	// checked is set once the track has been looked up, so it's only resumed when it starts
+	checked bool
+}
+
+func (t resumeTrack) positionAt(now time.Time) time.Duration {
+	pos := t.position
+	if t.playing {
+		pos += now.Sub(t.at)
+	}
+	if t.media.Length > 0 && pos > t.media.Length {
+		pos = t.media.Length
+	}
+
+	return pos
+}
+
+// resumer remembers where long tracks were left, and seeks back there when they're played again.
+// Players that do this by themselves are already past the position, and are left alone.
+type resumer struct {
+	tracks map[string]*resumeTrack
+	// skip are the urls of tracks about to be seeked elsewhere, e.g. by a bookmark, and until when
+	skip map[string]time.Time
+
+	// Guards the tracks and the state file
+	sync.Mutex
+}
+
+func newResumer() *resumer {
+	return &resumer{tracks: map[string]*resumeTrack{}, skip: map[string]time.Time{}}
+}
+
+func isLongMedia(m mpris.Media) bool {
+	c := getConfig().Resume
+	return c.Enabled && m.URL != "" && m.Length >= c.MinLength
+}
+
+// Update follows the position of a player, and resumes long tracks when they start
+func (r *resumer) Update(p mpris.Player) {
+	r.Lock()
+	defer r.Unlock()
+
+	now := time.Now()
+	m := p.GetMetadata()
+
+	t, ok := r.tracks[p.Name]
+	if ok && !isSameTrack(t.media, m) {
+		r.save(*t, now)
+		ok = false
+	}
+	if !ok {
+		t = &resumeTrack{}
+		r.tracks[p.Name] = t
+	}
+
+	t.media = m
+	t.position = p.Position()
+	t.at = now
+	t.playing = p.IsPlaying()
+
+	// The length is sometimes only known after the track has started
+	if t.checked || !isLongMedia(m) {
+		return
+	}
+	t.checked = true
+
+	if until, ok := r.skip[m.URL]; ok {
+		delete(r.skip, m.URL)
+		if now.Before(until) {
+			return
+		}
+	}
+
+	saved, ok := r.load().Positions[m.URL]
+	if ok && saved.Position > t.position+resumeMargin {
+		go resumeAt(p, saved.Position)
+	}
+}
+
+// Skip doesn't resume the track the next time it starts, since it's about to be seeked anyway
+func (r *resumer) Skip(url string, d time.Duration) {
+	r.Lock()
+	defer r.Unlock()
+
+	now := time.Now()
+	for u, until := range r.skip {
+		if now.After(until) {
+			delete(r.skip, u)
+		}
+	}
+	r.skip[url] = now.Add(d)
+}
+
+// Finish remembers the position of a player that went away
+func (r *resumer) Finish(name string) {
+	r.Lock()
+	defer r.Unlock()
+
+	if t, ok := r.tracks[name]; ok {
+		r.save(*t, time.Now())
+		delete(r.tracks, name)
+	}
+}
+
+// FinishAll remembers the positions of every player, e.g. when the daemon is stopped
+func (r *resumer) FinishAll() {
+	r.Lock()
+	defer r.Unlock()
+
+	now := time.Now()
+	for name, t := range r.tracks {
+		r.save(*t, now)
+		delete(r.tracks, name)
+	}
+}
+
+func (r *resumer) load() resumeState {
+	var s resumeState
+	if err := readState(statePath(resumeStateFile), &s); err != nil {
+		log.Printf("Could not load resume positions: %s", err)
+	}
+	if s.Positions == nil {
+		s.Positions = map[string]resumePosition{}
+	}
+
+	return s
+}
+
+// save expects r to be locked
+func (r *resumer) save(t resumeTrack, now time.Time) {
+	if !isLongMedia(t.media) {
+		return
+	}
+
+	s := r.load()
+
+	pos := t.positionAt(now)
+	if pos < resumeMargin || t.media.Length-pos < resumeMargin {
+		if _, ok := s.Positions[t.media.URL]; !ok {
+			return
+		}
+		delete(s.Positions, t.media.URL)
+	} else {
+		s.Positions[t.media.URL] = resumePosition{Position: pos, Length: t.media.Length, Saved: now}
+	}
+
+	if len(s.Positions) > maxResumePositions {
+		urls := make([]string, 0, len(s.Positions))
+		for url := range s.Positions {
+			urls = append(urls, url)
+		}
+		sort.Slice(urls, func(a, b int) bool {
+			return s.Positions[urls[a]].Saved.After(s.Positions[urls[b]].Saved)
+		})
+		for _, url := range urls[maxResumePositions:] {
+			delete(s.Positions, url)
+		}
+	}
+
+	if err := writeState(statePath(resumeStateFile), s); err != nil {
+		log.Printf("Could not save resume position (%s): %s", truncate(80, t.media.URL), err)
+	}
+}
+
+func resumeAt(p mpris.Player, pos time.Duration) {
+	log.Printf("Resuming %s at %s\n", truncate(80, p.GetMetadata().URL), formatDuration(pos))
+
+	if err := p.SeekTo(pos); err != nil {
+		log.Printf("Could not resume (%s): %s", p.Name, err)
+	}
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/ingentingalls/rofi-media/desktop"
+	"github.com/ingentingalls/rofi-media/mpris"
+)
+
+// PlayerMatch matches players by bus name, Identity or DesktopEntry.
+// Every pattern that is set has to match.
+type PlayerMatch struct {
+	BusName      string `toml:"bus_name"`
+	Identity     string `toml:"identity"`
+	DesktopEntry string `toml:"desktop_entry"`
+	Regex        bool   `toml:"regex"`
+
+	matchers []fieldMatcher
+}
+
+type RuleConfig struct {
+	PlayerMatch
+
+	Hide  bool   `toml:"hide"`
+	Pin   bool   `toml:"pin"`
+	Alias string `toml:"alias"`
+}
+
+type playerField int
+
+const (
+	playerFieldBusName playerField = iota
+	playerFieldIdentity
+	playerFieldDesktopEntry
+)
+
+type fieldMatcher struct {
+	field playerField
+	match func(s string) bool
+}
+
+type playerRule struct {
+	Hide  bool
+	Pin   bool
+	Alias string
+}
+
+func (r *PlayerMatch) compile() error {
+	r.matchers = nil
+
+	patterns := []struct {
+		field   playerField
+		pattern string
+	}{
+		{playerFieldBusName, r.BusName},
+		{playerFieldIdentity, r.Identity},
+		{playerFieldDesktopEntry, r.DesktopEntry},
+	}
+
+	for _, p := range patterns {
+		if p.pattern == "" {
+			continue
+		}
+
+		m, err := compilePattern(p.pattern, r.Regex)
+		if err != nil {
+			return err
+		}
+
+		r.matchers = append(r.matchers, fieldMatcher{field: p.field, match: m})
+	}
+
+	if len(r.matchers) == 0 {
+		return fmt.Errorf("needs at least one of bus_name, identity or desktop_entry")
+	}
+
+	return nil
+}
+
+func compilePattern(pattern string, isRegex bool) (func(s string) bool, error) {
+	if isRegex {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex %q: %w", pattern, err)
+		}
+
+		return re.MatchString, nil
+	}
+
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("invalid glob %q: %w", pattern, err)
+	}
+
+	return func(s string) bool {
+		ok, _ := path.Match(pattern, s)
+		return ok
+	}, nil
+}
+
+func (r PlayerMatch) matches(name, identity, desktopEntry string) bool {
+	for _, m := range r.matchers {
+		var s string
+		switch m.field {
+		case playerFieldBusName:
+			s = name
+		case playerFieldIdentity:
+			s = identity
+		case playerFieldDesktopEntry:
+			s = desktopEntry
+		}
+
+		if !m.match(s) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func compileRules(rules []RuleConfig) error {
+	for i := range rules {
+		if err := rules[i].compile(); err != nil {
+			return fmt.Errorf("rule %d: %w", i+1, err)
+		}
+	}
+
+	return nil
+}
+
+// ruleFor merges every matching rule. The first alias found wins.
+func (c *Config) ruleFor(name, identity, desktopEntry string) playerRule {
+	var pr playerRule
+
+	short := strings.TrimPrefix(name, "org.mpris.MediaPlayer2.")
+	for _, h := range c.Players.Hidden {
+		if h == name || h == short {
+			pr.Hide = true
+		}
+	}
+
+	for _, r := range c.Rules {
+		if !r.matches(name, identity, desktopEntry) {
+			continue
+		}
+
+		pr.Hide = pr.Hide || r.Hide
+		pr.Pin = pr.Pin || r.Pin
+		if pr.Alias == "" {
+			pr.Alias = r.Alias
+		}
+	}
+
+	return pr
+}
+
+func (c *Config) playerRule(p mpris.Player) playerRule {
+	return c.ruleFor(p.Name, p.Identity, p.DesktopEntry)
+}
+
+func (c *Config) isHidden(p mpris.Player) bool {
+	return c.playerRule(p).Hide
+}
+
+func (c *Config) displayName(p mpris.Player) string {
+	if alias := c.playerRule(p).Alias; alias != "" {
+		return alias
+	}
+
+	if e, ok := desktop.Lookup(p.DesktopEntry); ok && e.Name != "" {
+		return e.Name
+	}
+
+	return p.Short
+}
+
+// playerRank returns the position of the player in the configured order.
+// Pinned players are ranked before every other player, and players that
+// aren't listed are ranked after every listed player.
+func (c *Config) playerRank(p mpris.Player) int {
+	rank := len(c.Players.Order)
+	for i, o := range c.Players.Order {
+		if o == p.Name || o == p.Short {
+			rank = i
+			break
+		}
+	}
+
+	if c.playerRule(p).Pin {
+		rank -= len(c.Players.Order) + 1
+	}
+
+	return rank
+}
@@ -0,0 +1,361 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const scrobbleQueueFile = "scrobbles.json"
+const scrobbleBatchSize = 100
+const scrobbleTimeout = 30 * time.Second
+
+const scrobbleMinRetry = 30 * time.Second
+const scrobbleMaxRetry = 30 * time.Minute
+
+// A track counts as listened after half its length, or after this long for long tracks
+const scrobbleThreshold = 4 * time.Minute
+
+const defaultScrobbleURL = "https://api.listenbrainz.org"
+
+// ErrScrobbleRejected is returned when the server won't ever accept the listens, so retrying is pointless
+var ErrScrobbleRejected = errors.New("listens were rejected")
+
+// scrobbleRetryError is returned when the server says how long to wait before trying again
+type scrobbleRetryError struct {
+	after time.Duration
+	err   error
+}
+
+func (e *scrobbleRetryError) Error() string {
+	return e.err.Error()
+}
+
+func (e *scrobbleRetryError) Unwrap() error {
+	return e.err
+}
+
+// scrobble is a listen in the ListenBrainz format
+type scrobble struct {
+	ListenedAt    int64         `json:"listened_at"`
+	TrackMetadata trackMetadata `json:"track_metadata"`
+}
+
+type trackMetadata struct {
+	ArtistName     string         `json:"artist_name"`
+	TrackName      string         `json:"track_name"`
+	ReleaseName    string         `json:"release_name,omitempty"`
+	AdditionalInfo additionalInfo `json:"additional_info"`
+}
+
+type additionalInfo struct {
+	DurationMs       int64  `json:"duration_ms,omitempty"`
+	MediaPlayer      string `json:"media_player,omitempty"`
+	SubmissionClient string `json:"submission_client"`
+	OriginURL        string `json:"origin_url,omitempty"`
+}
+
+type submitListens struct {
+	ListenType string     `json:"listen_type"`
+	Payload    []scrobble `json:"payload"`
+}
+
+// scrobbleQueue holds the listens that haven't been submitted yet, so they survive being offline
+type scrobbleQueue struct {
+	Listens []scrobble `json:"listens"`
+}
+
+// isListened decides if a listen counts, by the same rules as Last.fm and ListenBrainz
+func isListened(l listen) bool {
+	threshold := scrobbleThreshold
+	if half := l.Media.Length / 2; half > 0 && half < threshold {
+		threshold = half
+	}
+
+	return l.Listened >= threshold
+}
+
+func newScrobble(l listen) scrobble {
+	player := l.Identity
+	if player == "" {
+		player = l.Player
+	}
+
+	return scrobble{
+		ListenedAt: l.Started.Unix(),
+		TrackMetadata: trackMetadata{
+			ArtistName:  l.Media.Artist,
+			TrackName:   l.Media.Title,
+			ReleaseName: l.Media.Album,
+			AdditionalInfo: additionalInfo{
+				DurationMs:       l.Media.Length.Milliseconds(),
+				MediaPlayer:      player,
+				SubmissionClient: "rofi-media",
+				OriginURL:        originURL(l.Media.URL),
+			},
+		},
+	}
+}
+
+// originURL keeps urls that point somewhere else, but not paths on this computer
+func originURL(url string) string {
+	if strings.HasPrefix(url, "http://") || strings.HasPrefix(url, "https://") {
+		return url
+	}
+
+	return ""
+}
+
+// scrobbler queues listens on disk and submits them to a ListenBrainz compatible server
+type scrobbler struct {
+	client *http.Client
+	wake   chan struct{}
+
+	// Guards the queue file
+	mu sync.Mutex
+}
+
+func newScrobbler() *scrobbler {
+	return &scrobbler{
+		client: &http.Client{Timeout: scrobbleTimeout},
+		wake:   make(chan struct{}, 1),
+	}
+}
+
+// Add queues the listen if it counts and wakes up the submission
+func (s *scrobbler) Add(l listen) {
+	if !getConfig().Scrobble.Enabled {
+		return
+	}
+	if !isListened(l) || l.Media.Title == "" || l.Media.Artist == "" {
+		return
+	}
+
+	err := s.update(func(q *scrobbleQueue) {
+		q.Listens = append(q.Listens, newScrobble(l))
+	})
+	if err != nil {
+		log.Printf("Could not queue scrobble (%s): %s", l.Media.Title, err)
+		return
+	}
+
+	s.Wake()
+}
+
+// Wake submits the queue right away
+func (s *scrobbler) Wake() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run submits the queue whenever a listen is added, and retries with a growing delay while it fails
+func (s *scrobbler) Run() {
+	go func() {
+		var retry time.Duration
+		var retryCh <-chan time.Time
+
+		for {
+			if err := s.flush(); err == nil {
+				retry = 0
+				retryCh = nil
+			} else {
+				var wait time.Duration
+				retry, wait = nextScrobbleRetry(retry, err)
+				log.Printf("Could not submit scrobbles, retrying in %s: %s", wait, err)
+				retryCh = time.After(wait)
+			}
+
+			select {
+			case <-s.wake:
+			case <-retryCh:
+			}
+		}
+	}()
+}
+
+// nextScrobbleRetry doubles the delay after every failure, but waits as long as the server asks to when it's rate limited.
+// It returns the new delay, and how long to wait this time.
+func nextScrobbleRetry(retry time.Duration, err error) (time.Duration, time.Duration) {
+	retry *= 2
+	if retry < scrobbleMinRetry {
+		retry = scrobbleMinRetry
+	}
+	if retry > scrobbleMaxRetry {
+		retry = scrobbleMaxRetry
+	}
+
+	var retryErr *scrobbleRetryError
+	if errors.As(err, &retryErr) && retryErr.after > 0 {
+		if retryErr.after > scrobbleMaxRetry {
+			return retry, scrobbleMaxRetry
+		}
+		return retry, retryErr.after
+	}
+
+	return retry, retry
+}
+
+func (s *scrobbler) load() (scrobbleQueue, error) {
+	var q scrobbleQueue
+	err := readState(statePath(scrobbleQueueFile), &q)
+	return q, err
+}
+
+func (s *scrobbler) update(fn func(q *scrobbleQueue)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	q, err := s.load()
+	if err != nil {
+		return err
+	}
+
+	fn(&q)
+
+	return writeState(statePath(scrobbleQueueFile), q)
+}
+
+// flush submits the queue in batches until it's empty or a batch fails
+func (s *scrobbler) flush() error {
+	for {
+		c := getConfig().Scrobble
+		if !c.Enabled {
+			return nil
+		}
+
+		s.mu.Lock()
+		q, err := s.load()
+		s.mu.Unlock()
+		if err != nil {
+			return err
+		}
+		if len(q.Listens) == 0 {
+			return nil
+		}
+
+		n := len(q.Listens)
+		if n > scrobbleBatchSize {
+			n = scrobbleBatchSize
+		}
+
+		n, submitErr := s.submitBatch(c, q.Listens[:n])
+		if n == 0 {
+			return submitErr
+		}
+
+		// Listens are only ever appended, so the submitted ones are still first
+		err = s.update(func(q *scrobbleQueue) {
+			if len(q.Listens) < n {
+				n = len(q.Listens)
+			}
+			q.Listens = q.Listens[n:]
+		})
+		if err != nil {
+			return err
+		}
+		if submitErr != nil {
+			return submitErr
+		}
+	}
+}
+
+// submitBatch returns how many listens from the start of the batch were submitted or dropped.
+// A rejected batch is split until the listens the server won't accept are found, and only those are dropped.
+func (s *scrobbler) submitBatch(c ScrobbleConfig, listens []scrobble) (int, error) {
+	err := s.submit(c, listens)
+	if err == nil {
+		return len(listens), nil
+	}
+	if !errors.Is(err, ErrScrobbleRejected) {
+		return 0, err
+	}
+
+	if len(listens) == 1 {
+		log.Printf("Dropping scrobble (%s): %s", listens[0].TrackMetadata.TrackName, err)
+		return 1, nil
+	}
+
+	half := len(listens) / 2
+	done, err := s.submitBatch(c, listens[:half])
+	if err != nil || done < half {
+		return done, err
+	}
+
+	rest, err := s.submitBatch(c, listens[half:])
+	return half + rest, err
+}
+
+func (s *scrobbler) submit(c ScrobbleConfig, listens []scrobble) error {
+	listenType := "import"
+	if len(listens) == 1 {
+		listenType = "single"
+	}
+
+	body, err := json.Marshal(submitListens{ListenType: listenType, Payload: listens})
+	if err != nil {
+		return fmt.Errorf("scrobble: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), scrobbleTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(c.URL, "/")+"/1/submit-listens", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("scrobble: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Token "+c.Token)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("scrobble: %w", err)
+	}
+	defer resp.Body.Close()
+
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<10))
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil
+	// Bad listens would block the queue forever. A bad token is fixed in the config, so it's retried
+	case resp.StatusCode == http.StatusBadRequest:
+		return fmt.Errorf("scrobble: %w: %s", ErrScrobbleRejected, strings.TrimSpace(string(msg)))
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return &scrobbleRetryError{
+			after: retryAfter(resp.Header, time.Now()),
+			err:   fmt.Errorf("scrobble: %s: %s", resp.Status, strings.TrimSpace(string(msg))),
+		}
+	default:
+		return fmt.Errorf("scrobble: %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+}
+
+// retryAfter reads how long the server asks to wait, from Retry-After or the ListenBrainz rate limit headers.
+// It's 0 when the server doesn't say.
+func retryAfter(h http.Header, now time.Time) time.Duration {
+	if v := h.Get("Retry-After"); v != "" {
+		if seconds, err := strconv.Atoi(v); err == nil && seconds >= 0 {
+			return time.Duration(seconds) * time.Second
+		}
+		if t, err := http.ParseTime(v); err == nil && t.After(now) {
+			return t.Sub(now)
+		}
+	}
+
+	if seconds, err := strconv.Atoi(h.Get("X-RateLimit-Reset-In")); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second
+	}
+
+	return 0
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// listenBrainz is a fake server that accepts every listen except those named "bad"
+type listenBrainz struct {
+	status     int
+	retryAfter string
+
+	requests int
+	accepted []string
+	sync.Mutex
+}
+
+func (lb *listenBrainz) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	lb.Lock()
+	defer lb.Unlock()
+
+	lb.requests++
+	if r.URL.Path != "/1/submit-listens" || r.Header.Get("Authorization") != "Token secret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if lb.status != 0 {
+		if lb.retryAfter != "" {
+			w.Header().Set("Retry-After", lb.retryAfter)
+		}
+		w.WriteHeader(lb.status)
+		return
+	}
+
+	var body submitListens
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	for _, l := range body.Payload {
+		if l.TrackMetadata.TrackName == "bad" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+	for _, l := range body.Payload {
+		lb.accepted = append(lb.accepted, l.TrackMetadata.TrackName)
+	}
+}
+
+func newTestScrobbler(t *testing.T, lb *listenBrainz, tracks ...string) *scrobbler {
+	t.Helper()
+
+	server := httptest.NewServer(lb)
+	t.Cleanup(server.Close)
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+
+	previous := getConfig()
+	c := *previous
+	c.Scrobble = ScrobbleConfig{Enabled: true, URL: server.URL + "/", Token: "secret"}
+	activeConfig.Store(&c)
+	t.Cleanup(func() { activeConfig.Store(previous) })
+
+	s := newScrobbler()
+	err := s.update(func(q *scrobbleQueue) {
+		for i, track := range tracks {
+			q.Listens = append(q.Listens, scrobble{
+				ListenedAt:    int64(i),
+				TrackMetadata: trackMetadata{ArtistName: "artist", TrackName: track},
+			})
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return s
+}
+
+func queuedTracks(t *testing.T, s *scrobbler) []string {
+	t.Helper()
+
+	q, err := s.load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tracks []string
+	for _, l := range q.Listens {
+		tracks = append(tracks, l.TrackMetadata.TrackName)
+	}
+
+	return tracks
+}
+
+func equalTracks(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func TestScrobblerFlush(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		retryAfter string
+		tracks     []string
+
+		accepted   []string
+		queued     []string
+		wantErr    bool
+		wantRetry  time.Duration
+		requestsAt int
+	}{
+		{
+			name:       "accepted",
+			tracks:     []string{"a", "b", "c"},
+			accepted:   []string{"a", "b", "c"},
+			requestsAt: 1,
+		},
+		{
+			name:     "only the rejected listen is dropped",
+			tracks:   []string{"a", "b", "bad", "c", "d"},
+			accepted: []string{"a", "b", "c", "d"},
+		},
+		{
+			name:     "every listen rejected",
+			tracks:   []string{"bad", "bad"},
+			accepted: nil,
+		},
+		{
+			name:       "rate limited",
+			status:     http.StatusTooManyRequests,
+			retryAfter: "7",
+			tracks:     []string{"a", "b"},
+			queued:     []string{"a", "b"},
+			wantErr:    true,
+			wantRetry:  7 * time.Second,
+			requestsAt: 1,
+		},
+		{
+			name:       "server error",
+			status:     http.StatusServiceUnavailable,
+			tracks:     []string{"a", "b"},
+			queued:     []string{"a", "b"},
+			wantErr:    true,
+			wantRetry:  scrobbleMinRetry,
+			requestsAt: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lb := &listenBrainz{status: tt.status, retryAfter: tt.retryAfter}
+			s := newTestScrobbler(t, lb, tt.tracks...)
+
+			err := s.flush()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("flush() error = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil {
+				if _, wait := nextScrobbleRetry(0, err); wait != tt.wantRetry {
+					t.Errorf("retry in %s, want %s", wait, tt.wantRetry)
+				}
+			}
+
+			if !equalTracks(lb.accepted, tt.accepted) {
+				t.Errorf("accepted %v, want %v", lb.accepted, tt.accepted)
+			}
+			if queued := queuedTracks(t, s); !equalTracks(queued, tt.queued) {
+				t.Errorf("queued %v, want %v", queued, tt.queued)
+			}
+			if tt.requestsAt > 0 && lb.requests != tt.requestsAt {
+				t.Errorf("%d requests, want %d", lb.requests, tt.requestsAt)
+			}
+		})
+	}
+}
+
+func TestScrobblerFlushBatches(t *testing.T) {
+	tracks := make([]string, scrobbleBatchSize+20)
+	for i := range tracks {
+		tracks[i] = "track"
+	}
+	tracks[scrobbleBatchSize+5] = "bad"
+
+	lb := &listenBrainz{}
+	s := newTestScrobbler(t, lb, tracks...)
+
+	if err := s.flush(); err != nil {
+		t.Fatalf("flush() error = %v", err)
+	}
+	if len(lb.accepted) != len(tracks)-1 {
+		t.Errorf("accepted %d listens, want %d", len(lb.accepted), len(tracks)-1)
+	}
+	if queued := queuedTracks(t, s); len(queued) != 0 {
+		t.Errorf("queued %v, want none", queued)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		header http.Header
+		want   time.Duration
+	}{
+		{"seconds", http.Header{"Retry-After": {"120"}}, 2 * time.Minute},
+		{"date", http.Header{"Retry-After": {now.Add(time.Minute).Format(http.TimeFormat)}}, time.Minute},
+		{"listenbrainz", http.Header{"X-Ratelimit-Reset-In": {"5"}}, 5 * time.Second},
+		{"invalid", http.Header{"Retry-After": {"soon"}}, 0},
+		{"missing", http.Header{}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := retryAfter(tt.header, now); got != tt.want {
+				t.Errorf("retryAfter() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNextScrobbleRetry(t *testing.T) {
+	err := errors.New("offline")
+	limited := &scrobbleRetryError{after: time.Hour, err: err}
+
+	tests := []struct {
+		name      string
+		retry     time.Duration
+		err       error
+		wantRetry time.Duration
+		wantWait  time.Duration
+	}{
+		{"first failure", 0, err, scrobbleMinRetry, scrobbleMinRetry},
+		{"doubles", time.Minute, err, 2 * time.Minute, 2 * time.Minute},
+		{"capped", scrobbleMaxRetry, err, scrobbleMaxRetry, scrobbleMaxRetry},
+		{"rate limited for too long", 0, limited, scrobbleMinRetry, scrobbleMaxRetry},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			retry, wait := nextScrobbleRetry(tt.retry, tt.err)
+			if retry != tt.wantRetry || wait != tt.wantWait {
+				t.Errorf("nextScrobbleRetry() = %s, %s, want %s, %s", retry, wait, tt.wantRetry, tt.wantWait)
+			}
+		})
+	}
+}
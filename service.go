@@ -0,0 +1,165 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/ingentingalls/rofi-media/mpris"
+)
+
+const daemonObjectPath = "/com/github/ingentingalls/RofiMedia"
+const daemonInterface = daemonBusName
+
+var ErrDaemonNotRunning = errors.New("the daemon isn't running, start it with: rofi-media daemon")
+
+// daemonService is exported by the daemon so rofi and the command line can control it
+type daemonService struct {
+	players *playerList
+	sleep   *sleepTimer
+	resumes *resumer
+}
+
+// Pause fades out and pauses the players in the background, so rofi can exit in the middle of the fade
+func (s *daemonService) Pause(names []string) *dbus.Error {
+	go pausePlayers(s.find(names))
+	return nil
+}
+
+// Play starts the players and fades them in in the background
+func (s *daemonService) Play(names []string) *dbus.Error {
+	go playPlayers(s.find(names))
+	return nil
+}
+
+// SkipResume keeps the track from being resumed when it starts within the given seconds, e.g. when a bookmark opens it
+func (s *daemonService) SkipResume(url string, seconds int64) *dbus.Error {
+	s.resumes.Skip(url, time.Duration(seconds)*time.Second)
+	return nil
+}
+
+func (s *daemonService) find(names []string) []mpris.Player {
+	players := s.players.All()
+
+	var found []mpris.Player
+	for _, name := range names {
+		if p, _ := separatePlayers(players, name); p != nil {
+			found = append(found, *p)
+		}
+	}
+
+	return found
+}
+
+func (s *daemonService) SetSleepTimer(mode string, seconds int64) *dbus.Error {
+	if err := s.sleep.Set(sleepMode(mode), time.Duration(seconds)*time.Second); err != nil {
+		return dbus.MakeFailedError(err)
+	}
+
+	return nil
+}
+
+func (s *daemonService) CancelSleepTimer() *dbus.Error {
+	s.sleep.Cancel()
+	return nil
+}
+
+// GetSleepTimer returns an empty mode when there's no timer, and -1 when the remaining time isn't known
+func (s *daemonService) GetSleepTimer() (string, int64, *dbus.Error) {
+	status := s.sleep.Status()
+
+	remaining := int64(-1)
+	if status.Remaining >= 0 {
+		remaining = int64(status.Remaining.Round(time.Second) / time.Second)
+	}
+
+	return string(status.Mode), remaining, nil
+}
+
+func exportDaemonService(conn *dbus.Conn, s *daemonService) error {
+	if err := conn.Export(s, daemonObjectPath, daemonInterface); err != nil {
+		return fmt.Errorf("could not export %s: %w", daemonObjectPath, err)
+	}
+
+	return nil
+}
+
+type daemonClient struct {
+	conn *dbus.Conn
+	obj  dbus.BusObject
+}
+
+func newDaemonClient(conn *dbus.Conn) *daemonClient {
+	return &daemonClient{conn: conn, obj: conn.Object(daemonBusName, daemonObjectPath)}
+}
+
+func (c *daemonClient) IsRunning() bool {
+	var hasOwner bool
+	err := c.conn.BusObject().Call("org.freedesktop.DBus.NameHasOwner", 0, daemonBusName).Store(&hasOwner)
+	if err != nil {
+		log.Printf("Could not look for the daemon: %s", err)
+	}
+
+	return hasOwner
+}
+
+func (c *daemonClient) call(method string, args ...any) *dbus.Call {
+	return c.obj.Call(daemonInterface+"."+method, 0, args...)
+}
+
+func (c *daemonClient) Pause(names []string) error {
+	if err := c.call("Pause", names).Err; err != nil {
+		return fmt.Errorf("daemon.Pause: %w", err)
+	}
+
+	return nil
+}
+
+func (c *daemonClient) Play(names []string) error {
+	if err := c.call("Play", names).Err; err != nil {
+		return fmt.Errorf("daemon.Play: %w", err)
+	}
+
+	return nil
+}
+
+func (c *daemonClient) SkipResume(url string, d time.Duration) error {
+	if err := c.call("SkipResume", url, int64(d/time.Second)).Err; err != nil {
+		return fmt.Errorf("daemon.SkipResume: %w", err)
+	}
+
+	return nil
+}
+
+func (c *daemonClient) SetSleepTimer(mode sleepMode, d time.Duration) error {
+	if err := c.call("SetSleepTimer", string(mode), int64(d/time.Second)).Err; err != nil {
+		return fmt.Errorf("daemon.SetSleepTimer: %w", err)
+	}
+
+	return nil
+}
+
+func (c *daemonClient) CancelSleepTimer() error {
+	if err := c.call("CancelSleepTimer").Err; err != nil {
+		return fmt.Errorf("daemon.CancelSleepTimer: %w", err)
+	}
+
+	return nil
+}
+
+func (c *daemonClient) SleepTimer() (sleepStatus, error) {
+	var mode string
+	var remaining int64
+	if err := c.call("GetSleepTimer").Store(&mode, &remaining); err != nil {
+		return sleepStatus{}, fmt.Errorf("daemon.SleepTimer: %w", err)
+	}
+
+	s := sleepStatus{Mode: sleepMode(mode), Remaining: -1}
+	if remaining >= 0 {
+		s.Remaining = time.Duration(remaining) * time.Second
+	}
+
+	return s, nil
+}
@@ -0,0 +1,427 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/ingentingalls/rofi"
+	"github.com/ingentingalls/rofi-media/mpris"
+)
+
+const sleepTickInterval = time.Second
+
+type sleepMode string
+
+const (
+	sleepModeDuration sleepMode = "duration"
+	sleepModeTrack    sleepMode = "track"
+	sleepModeQueue    sleepMode = "queue"
+)
+
+type sleepStatus struct {
+	Mode sleepMode
+	// Remaining is negative when it isn't known yet, e.g. before the last track of the queue
+	Remaining time.Duration
+}
+
+func (s sleepStatus) IsActive() bool {
+	return s.Mode != ""
+}
+
+// sleepTimer fades out and pauses the players after a while, or when the current track or the queue ends.
+// It runs in the daemon, since rofi is closed long before it fires.
+type sleepTimer struct {
+	players *playerList
+
+	mode  sleepMode
+	until time.Time
+	// player is the player that was playing when the timer was set, and track what it was playing
+	player string
+	track  mpris.Media
+	stop   chan struct{}
+
+	sync.Mutex
+}
+
+func newSleepTimer(players *playerList) *sleepTimer {
+	return &sleepTimer{players: players}
+}
+
+func (t *sleepTimer) Set(mode sleepMode, d time.Duration) error {
+	t.Lock()
+	defer t.Unlock()
+
+	var active *mpris.Player
+	for _, p := range t.players.All() {
+		if p.IsPlaying() {
+			p := p
+			active = &p
+			break
+		}
+	}
+
+	switch mode {
+	case sleepModeDuration:
+		if d <= 0 {
+			return fmt.Errorf("sleep: the duration has to be positive")
+		}
+		t.until = time.Now().Add(d)
+	case sleepModeTrack, sleepModeQueue:
+		if active == nil {
+			return fmt.Errorf("sleep: nothing is playing")
+		}
+		if mode == sleepModeQueue && (active.GetLoopStatus() == mpris.LoopStatusPlaylist || active.GetLoopStatus() == mpris.LoopStatusTrack) {
+			return fmt.Errorf("sleep: %s repeats, so its queue never ends", active.Name)
+		}
+	default:
+		return fmt.Errorf("sleep: unknown mode %q", mode)
+	}
+
+	t.clear()
+	t.mode = mode
+	t.player = ""
+	t.track = mpris.Media{}
+	if active != nil {
+		t.player = active.Name
+		t.track = active.GetMetadata()
+	}
+
+	stop := make(chan struct{})
+	t.stop = stop
+	go t.run(stop)
+
+	log.Printf("Sleep timer set: %s\n", formatSleepStatus(t.status()))
+
+	return nil
+}
+
+func (t *sleepTimer) Cancel() {
+	t.Lock()
+	defer t.Unlock()
+
+	t.clear()
+}
+
+func (t *sleepTimer) Status() sleepStatus {
+	t.Lock()
+	defer t.Unlock()
+
+	return t.status()
+}
+
+// clear expects the timer to be locked
+func (t *sleepTimer) clear() {
+	if t.stop != nil {
+		close(t.stop)
+		t.stop = nil
+	}
+	t.mode = ""
+}
+
+// status expects the timer to be locked
+func (t *sleepTimer) status() sleepStatus {
+	if t.mode == "" {
+		return sleepStatus{}
+	}
+
+	if t.mode == sleepModeDuration {
+		return sleepStatus{Mode: t.mode, Remaining: time.Until(t.until)}
+	}
+
+	s := sleepStatus{Mode: t.mode, Remaining: -1}
+	selected, _ := separatePlayers(t.players.All(), t.player)
+	if selected == nil {
+		return s
+	}
+
+	length := selected.GetMetadata().Length
+	// The end of the queue is only known once the last track plays
+	if length <= 0 || t.mode == sleepModeQueue && selected.CanGoNext() {
+		return s
+	}
+
+	s.Remaining = length - selected.Position()
+	return s
+}
+
+func (t *sleepTimer) run(stop chan struct{}) {
+	ticker := time.NewTicker(sleepTickInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		targets, fade, ok := t.tick(stop)
+		if !ok {
+			continue
+		}
+
+		log.Printf("Sleep timer fired, pausing %d players\n", len(targets))
+
+		var wg sync.WaitGroup
+		for _, p := range targets {
+			wg.Add(1)
+			go func(p mpris.Player) {
+				defer wg.Done()
+				if err := fadeOutAndPause(p, fade); err != nil {
+					log.Printf("Could not pause (%s): %s", p.Name, err)
+				}
+			}(p)
+		}
+		wg.Wait()
+
+		return
+	}
+}
+
+// tick returns the players to pause and how long to fade them out once the timer fires
+func (t *sleepTimer) tick(stop chan struct{}) ([]mpris.Player, time.Duration, bool) {
+	t.Lock()
+	defer t.Unlock()
+
+	// Replaced or cancelled in the meantime
+	if t.stop != stop {
+		return nil, 0, false
+	}
+
+	fade := getConfig().Sleep.Fade
+	selected, _ := separatePlayers(t.players.All(), t.player)
+
+	if t.mode != sleepModeDuration {
+		// Stopping by itself is the end of the queue
+		if selected == nil || selected.GetPlaybackStatus() == mpris.PlaybackStatusStopped {
+			return t.ended()
+		}
+		if !selected.IsPlaying() {
+			// Some players pause at the end instead of stopping
+			if s := t.status(); s.Remaining >= 0 && s.Remaining <= sleepTickInterval {
+				return t.ended()
+			}
+			return nil, 0, false
+		}
+
+		if m := selected.GetMetadata(); !isSameTrack(m, t.track) {
+			if t.mode == sleepModeTrack {
+				// The track ended early, e.g. by skipping it, so the next one fades out like the track would have
+				t.clear()
+				return t.targets(selected), fade, true
+			}
+			t.track = m
+		}
+	}
+
+	s := t.status()
+	if s.Remaining < 0 || s.Remaining > fade {
+		return nil, 0, false
+	}
+
+	t.clear()
+	return t.targets(selected), s.Remaining, true
+}
+
+// ended fires the timer when the player stopped by itself at the end.
+// It has nothing left to pause, but the other players are paused when the timer pauses all of them.
+// It expects the timer to be locked.
+func (t *sleepTimer) ended() ([]mpris.Player, time.Duration, bool) {
+	t.clear()
+
+	if !getConfig().Sleep.PauseAll {
+		log.Println("Sleep timer fired, the player stopped by itself")
+		return nil, 0, false
+	}
+
+	return t.targets(nil), getConfig().Sleep.Fade, true
+}
+
+// targets expects the timer to be locked
+func (t *sleepTimer) targets(selected *mpris.Player) []mpris.Player {
+	if selected != nil && selected.IsPlaying() && !getConfig().Sleep.PauseAll {
+		return []mpris.Player{*selected}
+	}
+
+	var playing []mpris.Player
+	for _, p := range t.players.All() {
+		if p.IsPlaying() {
+			playing = append(playing, p)
+		}
+	}
+
+	return playing
+}
+
+func isSameTrack(a, b mpris.Media) bool {
+	return a.ID == b.ID && a.URL == b.URL && a.Title == b.Title
+}
+
+func formatSleepStatus(s sleepStatus) string {
+	var status string
+	switch s.Mode {
+	case sleepModeDuration:
+		return "Sleeping in " + formatDuration(s.Remaining)
+	case sleepModeTrack:
+		status = "Sleeping after this track"
+	case sleepModeQueue:
+		status = "Sleeping after the queue"
+	default:
+		return ""
+	}
+
+	if s.Remaining >= 0 {
+		status += " (" + formatDuration(s.Remaining) + ")"
+	}
+
+	return status
+}
+
+// shortDuration formats whole minutes and hours without the zero units, e.g. 1h30m
+func shortDuration(d time.Duration) string {
+	s := d.String()
+	if d%time.Minute == 0 {
+		s = s[:len(s)-2]
+	}
+	if d%time.Hour == 0 {
+		s = s[:len(s)-2]
+	}
+
+	return s
+}
+
+// parseSleepMode parses "track", "queue" or a duration
+func parseSleepMode(s string) (sleepMode, time.Duration, error) {
+	switch mode := sleepMode(s); mode {
+	case sleepModeTrack, sleepModeQueue:
+		return mode, 0, nil
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return "", 0, fmt.Errorf("sleep: expected track, queue or a duration: %w", err)
+	}
+
+	return sleepModeDuration, d, nil
+}
+
+func showSleepAction() []rofi.Option {
+	if daemon == nil || !daemon.IsRunning() {
+		return nil
+	}
+
+	return []rofi.Option{{
+		Label: "Sleep timer",
+		Cmds:  []string{"sleepTimer"},
+		Icon:  getConfig().Icons.Sleep,
+	}}
+}
+
+func showSleepTimer(s sleepStatus) []rofi.Option {
+	c := getConfig()
+
+	var opts []rofi.Option
+	for _, d := range c.Sleep.Durations {
+		opts = append(opts, rofi.Option{
+			Label: "In " + shortDuration(d),
+			Cmds:  []string{"setSleep"},
+			Icon:  c.Icons.Sleep,
+			Value: d.String(),
+		})
+	}
+
+	opts = append(opts,
+		rofi.Option{
+			Label: "After this track",
+			Cmds:  []string{"setSleep"},
+			Icon:  c.Icons.Sleep,
+			Value: string(sleepModeTrack),
+		},
+		rofi.Option{
+			Label: "After the queue",
+			Cmds:  []string{"setSleep"},
+			Icon:  c.Icons.Sleep,
+			Value: string(sleepModeQueue),
+		},
+	)
+
+	if s.IsActive() {
+		opts = append(opts, rofi.Option{
+			Label: "Cancel sleep timer",
+			Cmds:  []string{"cancelSleep"},
+			Icon:  c.Icons.Stop,
+		})
+	}
+
+	opts = append(opts, rofi.Option{
+		Label: "Back",
+		Cmds:  []string{"showAll"},
+		Icon:  c.Icons.Back,
+	})
+
+	return opts
+}
+
+// sleepMessage shows the sleep timer above the players
+func sleepMessage() string {
+	if daemon == nil {
+		return " "
+	}
+
+	s, err := daemon.SleepTimer()
+	if err != nil || !s.IsActive() {
+		return " "
+	}
+
+	return formatSleepStatus(s)
+}
+
+// runSleep sets, shows or cancels the sleep timer of the daemon from the command line
+func runSleep(args []string) {
+	conn, err := dbus.SessionBus()
+	if err != nil {
+		log.Fatalf("sleep: could not create a connection to the bus: %s", err)
+	}
+
+	client := newDaemonClient(conn)
+	if !client.IsRunning() {
+		log.Fatalf("sleep: %s", ErrDaemonNotRunning)
+	}
+
+	if len(args) == 0 {
+		s, err := client.SleepTimer()
+		if err != nil {
+			log.Fatalf("sleep: %s", err)
+		}
+		if !s.IsActive() {
+			fmt.Println("No sleep timer")
+			return
+		}
+		fmt.Println(formatSleepStatus(s))
+		return
+	}
+
+	if args[0] == "cancel" {
+		if err := client.CancelSleepTimer(); err != nil {
+			log.Fatalf("sleep: %s", err)
+		}
+		return
+	}
+
+	mode, d, err := parseSleepMode(args[0])
+	if err != nil {
+		log.Fatalf("%s", err)
+	}
+
+	if err := client.SetSleepTimer(mode, d); err != nil {
+		log.Fatalf("sleep: %s", err)
+	}
+
+	s, err := client.SleepTimer()
+	if err == nil {
+		fmt.Println(formatSleepStatus(s))
+	}
+}
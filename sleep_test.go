@@ -0,0 +1,72 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/ingentingalls/rofi-media/mpris"
+)
+
+func setTestTrack(p mpris.Player, title string, length time.Duration) {
+	p.UpdateProperties(map[string]dbus.Variant{
+		"Metadata": dbus.MakeVariant(map[string]dbus.Variant{
+			"xesam:title":  dbus.MakeVariant(title),
+			"mpris:length": dbus.MakeVariant(int64(length / time.Microsecond)),
+		}),
+		"Position": dbus.MakeVariant(int64(0)),
+	})
+}
+
+func TestSleepTimerTrack(t *testing.T) {
+	previous := getConfig()
+	c := *previous
+	c.Sleep.Fade = 5 * time.Second
+	c.Sleep.PauseAll = false
+	activeConfig.Store(&c)
+	t.Cleanup(func() { activeConfig.Store(previous) })
+
+	tests := []struct {
+		name     string
+		next     string
+		length   time.Duration
+		wantFire bool
+		wantFade time.Duration
+	}{
+		{name: "same track, far from the end", next: "first", length: time.Hour},
+		{name: "same track, at the end", next: "first", length: 3 * time.Second, wantFire: true, wantFade: 3 * time.Second},
+		{name: "skipped track fades out", next: "second", length: time.Hour, wantFire: true, wantFade: 5 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			list := &playerList{}
+			p := newTestPlayer("a", mpris.PlaybackStatusPlaying)
+			setTestTrack(p, "first", time.Hour)
+			list.add(p)
+
+			stop := make(chan struct{})
+			timer := &sleepTimer{players: list, mode: sleepModeTrack, player: "a", track: p.GetMetadata(), stop: stop}
+
+			setTestTrack(p, tt.next, tt.length)
+			targets, fade, ok := timer.tick(stop)
+			if ok != tt.wantFire {
+				t.Fatalf("tick() fired = %v, want %v", ok, tt.wantFire)
+			}
+			if !ok {
+				return
+			}
+
+			// The remaining time is extrapolated, so allow for the time the test took
+			if fade > tt.wantFade || fade < tt.wantFade-time.Second {
+				t.Errorf("tick() fade = %s, want %s", fade, tt.wantFade)
+			}
+			if len(targets) != 1 || targets[0].Name != "a" {
+				t.Errorf("tick() targets = %v, want [a]", playerNames(targets))
+			}
+			if timer.Status().IsActive() {
+				t.Error("the timer is still active after firing")
+			}
+		})
+	}
+}
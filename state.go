@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"syscall"
+)
+
+// stateDir returns XDG_STATE_HOME/rofi-media, which holds state that should survive restarts
+func stateDir() string {
+	dir := os.Getenv("XDG_STATE_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return path.Join(os.TempDir(), "rofi-media")
+		}
+		dir = path.Join(home, ".local", "state")
+	}
+
+	return path.Join(dir, "rofi-media")
+}
+
+func statePath(name string) string {
+	return path.Join(stateDir(), name)
+}
+
+// readState decodes a JSON state file into v. A missing file leaves v untouched.
+func readState(p string, v any) error {
+	data, err := os.ReadFile(p)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("state: %w", err)
+	}
+
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("state: could not decode %s: %w", p, err)
+	}
+
+	return nil
+}
+
+// lockState takes an exclusive lock on a state file until the returned function is called,
+// so rofi, the command line and the daemon don't lose each other's changes.
+// The lock is on a file next to it, since writeState replaces the state file.
+func lockState(p string) (func(), error) {
+	if err := os.MkdirAll(filepath.Dir(p), os.ModePerm); err != nil {
+		return nil, fmt.Errorf("state: Error while creating path: %w", err)
+	}
+
+	f, err := os.OpenFile(p+".lock", os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("state: %w", err)
+	}
+
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("state: could not lock %s: %w", p, err)
+	}
+
+	return func() {
+		syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+		f.Close()
+	}, nil
+}
+
+// writeState encodes v as JSON and replaces the state file atomically
+func writeState(p string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("state: could not encode %s: %w", p, err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(p), os.ModePerm); err != nil {
+		return fmt.Errorf("state: Error while creating path: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(p), filepath.Base(p)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("state: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	if _, err := tmp.Write(data); err != nil {
+		return fmt.Errorf("state: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("state: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), p); err != nil {
+		return fmt.Errorf("state: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"html"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ingentingalls/rofi"
+)
+
+const statsDateLayout = "2006-01-02"
+const statsBarWidth = 30
+
+// The rofi view is meant for a quick glance at the last week
+const statsViewRange = 7 * 24 * time.Hour
+const statsViewTop = 5
+
+type statsTotal struct {
+	Name    string `json:"name"`
+	Seconds int64  `json:"seconds"`
+	Plays   int    `json:"plays"`
+}
+
+type listeningStats struct {
+	Since time.Time `json:"since"`
+	Until time.Time `json:"until"`
+
+	Seconds int64 `json:"seconds"`
+	Plays   int   `json:"plays"`
+
+	Players []statsTotal `json:"players"`
+	Artists []statsTotal `json:"artists"`
+	Albums  []statsTotal `json:"albums"`
+	Genres  []statsTotal `json:"genres"`
+	Tracks  []statsTotal `json:"top_tracks"`
+
+	// Hours is the listening time in seconds by the hour of the day the tracks started
+	Hours [24]int64 `json:"hours"`
+}
+
+type statsCounter map[string]*statsTotal
+
+func (c statsCounter) add(name string, d time.Duration) {
+	if name == "" {
+		name = "Unknown"
+	}
+
+	t, ok := c[name]
+	if !ok {
+		t = &statsTotal{Name: name}
+		c[name] = t
+	}
+	t.Seconds += int64(d / time.Second)
+	t.Plays++
+}
+
+// top returns the totals with the most listening time first. n <= 0 returns all of them
+func (c statsCounter) top(n int) []statsTotal {
+	totals := make([]statsTotal, 0, len(c))
+	for _, t := range c {
+		totals = append(totals, *t)
+	}
+
+	sort.Slice(totals, func(a, b int) bool {
+		if totals[a].Seconds != totals[b].Seconds {
+			return totals[a].Seconds > totals[b].Seconds
+		}
+		return totals[a].Name < totals[b].Name
+	})
+
+	if n > 0 && len(totals) > n {
+		totals = totals[:n]
+	}
+
+	return totals
+}
+
+// computeStats sums up the history between since and until. Only the top entries of every total are kept
+func computeStats(since, until time.Time, top int) (listeningStats, error) {
+	s := listeningStats{Since: since, Until: until}
+
+	players := statsCounter{}
+	artists := statsCounter{}
+	albums := statsCounter{}
+	genres := statsCounter{}
+	tracks := statsCounter{}
+
+	err := readHistory(func(e historyEntry) {
+		if e.Started.Before(since) || !e.Started.Before(until) {
+			return
+		}
+
+		s.Seconds += int64(e.Listened / time.Second)
+		s.Plays++
+		s.Hours[e.Started.Local().Hour()] += int64(e.Listened / time.Second)
+
+		player := e.Identity
+		if player == "" {
+			player = strings.TrimPrefix(e.Player, "org.mpris.MediaPlayer2.")
+		}
+		players.add(player, e.Listened)
+		artists.add(e.Artist, e.Listened)
+
+		if e.Album != "" {
+			albumArtist := e.AlbumArtist
+			if albumArtist == "" {
+				albumArtist = e.Artist
+			}
+			albums.add(strings.Trim(albumArtist+" – "+e.Album, " –"), e.Listened)
+		}
+
+		// Genres are joined by mpris, and a track counts fully towards each of them
+		for _, g := range strings.Split(e.Genre, ", ") {
+			genres.add(g, e.Listened)
+		}
+
+		title := e.Title
+		if title == "" {
+			title = e.URL
+		}
+		tracks.add(strings.Trim(e.Artist+" – "+title, " –"), e.Listened)
+	})
+
+	s.Players = players.top(0)
+	s.Artists = artists.top(top)
+	s.Albums = albums.top(top)
+	s.Genres = genres.top(top)
+	s.Tracks = tracks.top(top)
+
+	return s, err
+}
+
+// parseSince parses a date like 2026-10-01, or a duration back from now like 7d or 12h
+func parseSince(s string, now time.Time) (time.Time, error) {
+	if t, err := time.ParseInLocation(statsDateLayout, s, time.Local); err == nil {
+		return t, nil
+	}
+
+	if strings.HasSuffix(s, "d") {
+		n, err := strconv.Atoi(strings.TrimSuffix(s, "d"))
+		if err != nil || n < 0 {
+			return time.Time{}, fmt.Errorf("stats: invalid number of days %q", s)
+		}
+		return now.AddDate(0, 0, -n), nil
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("stats: expected a date like 2026-10-01 or a duration like 7d: %q", s)
+	}
+
+	return now.Add(-d), nil
+}
+
+func formatSeconds(seconds int64) string {
+	return formatDuration(time.Duration(seconds) * time.Second)
+}
+
+func writeStatsTable(w io.Writer, s listeningStats) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintf(tw, "Listened %s in %d plays, %s – %s\n", formatSeconds(s.Seconds), s.Plays, s.Since.Format(statsDateLayout), s.Until.Format(statsDateLayout))
+
+	sections := []struct {
+		title  string
+		totals []statsTotal
+	}{
+		{"Players", s.Players},
+		{"Artists", s.Artists},
+		{"Albums", s.Albums},
+		{"Genres", s.Genres},
+		{"Top tracks", s.Tracks},
+	}
+	for _, section := range sections {
+		if len(section.totals) == 0 {
+			continue
+		}
+
+		fmt.Fprintf(tw, "\n%s\n", section.title)
+		for _, t := range section.totals {
+			fmt.Fprintf(tw, "  %s\t%s\t%d plays\n", t.Name, formatSeconds(t.Seconds), t.Plays)
+		}
+	}
+
+	var maxHour int64
+	for _, seconds := range s.Hours {
+		if seconds > maxHour {
+			maxHour = seconds
+		}
+	}
+	if maxHour > 0 {
+		fmt.Fprintf(tw, "\nBy hour of day\n")
+		for hour, seconds := range s.Hours {
+			bar := strings.Repeat("█", int(seconds*statsBarWidth/maxHour))
+			fmt.Fprintf(tw, "  %02d\t%s\t%s\n", hour, formatSeconds(seconds), bar)
+		}
+	}
+
+	return tw.Flush()
+}
+
+// runStats prints the listening statistics from the history
+func runStats(args []string) {
+	fs := flag.NewFlagSet("stats", flag.ExitOnError)
+	since := fs.String("since", "30d", "start of the range, as a date like 2026-10-01 or a duration back from now like 7d")
+	until := fs.String("until", "", "end of the range, as a date or a duration back from now. Defaults to now")
+	top := fs.Int("top", 10, "number of artists, albums, genres and tracks to show")
+	asJSON := fs.Bool("json", false, "print the statistics as JSON")
+	fs.Parse(args)
+
+	now := time.Now()
+	from, err := parseSince(*since, now)
+	if err != nil {
+		log.Fatalf("%s", err)
+	}
+
+	to := now
+	if *until != "" {
+		if to, err = parseSince(*until, now); err != nil {
+			log.Fatalf("%s", err)
+		}
+	}
+
+	s, err := computeStats(from, to, *top)
+	if err != nil {
+		log.Fatalf("%s", err)
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(s)
+	} else {
+		err = writeStatsTable(os.Stdout, s)
+	}
+	if err != nil {
+		log.Fatalf("stats: %s", err)
+	}
+}
+
+func showStatsAction() []rofi.Option {
+	if !getConfig().History.Enabled {
+		return nil
+	}
+
+	return []rofi.Option{{
+		Label: "Statistics",
+		Cmds:  []string{"stats"},
+		Icon:  getConfig().Icons.Stats,
+	}}
+}
+
+// showStats shows the top players, artists and genres of the last week
+func showStats(s listeningStats) []rofi.Option {
+	c := getConfig()
+
+	var opts []rofi.Option
+	sections := []struct {
+		title  string
+		totals []statsTotal
+	}{
+		{"Players", s.Players},
+		{"Artists", s.Artists},
+		{"Genres", s.Genres},
+	}
+	for _, section := range sections {
+		for i, t := range section.totals {
+			if i >= statsViewTop {
+				break
+			}
+
+			details := fmt.Sprintf("%s · %s · %d plays", section.title, formatSeconds(t.Seconds), t.Plays)
+			opts = append(opts, rofi.Option{
+				Label:    html.EscapeString(t.Name),
+				Category: fmt.Sprintf("<span color=\"%s\">%s</span>", html.EscapeString(c.Colors.Category), html.EscapeString(details)),
+				Icon:     c.Icons.Stats,
+				Cmds:     []string{"stats"},
+
+				IsMultiline: true,
+				UseMarkup:   true,
+			})
+		}
+	}
+
+	opts = append(opts, rofi.Option{
+		Label: "Back",
+		Cmds:  []string{"showAll"},
+		Icon:  c.Icons.Back,
+	})
+
+	return opts
+}
+
+func statsMessage(s listeningStats) string {
+	return fmt.Sprintf("Last 7 days: %s in %d plays", formatSeconds(s.Seconds), s.Plays)
+}
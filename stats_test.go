@@ -0,0 +1,124 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseSince(t *testing.T) {
+	now := time.Date(2026, 10, 18, 15, 30, 0, 0, time.Local)
+
+	tests := []struct {
+		s       string
+		want    time.Time
+		wantErr bool
+	}{
+		{s: "2026-10-01", want: time.Date(2026, 10, 1, 0, 0, 0, 0, time.Local)},
+		{s: "7d", want: time.Date(2026, 10, 11, 15, 30, 0, 0, time.Local)},
+		{s: "0d", want: now},
+		{s: "12h", want: now.Add(-12 * time.Hour)},
+		{s: "1h30m", want: now.Add(-90 * time.Minute)},
+		{s: "", wantErr: true},
+		{s: "d", wantErr: true},
+		{s: "-3d", wantErr: true},
+		{s: "1.5d", wantErr: true},
+		{s: "yesterday", wantErr: true},
+		{s: "2026-13-01", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseSince(tt.s, now)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseSince(%q) error = %v, want error %v", tt.s, err, tt.wantErr)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("parseSince(%q) = %s, want %s", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestComputeStats(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+
+	since := time.Date(2026, 10, 1, 0, 0, 0, 0, time.Local)
+	until := since.Add(24 * time.Hour)
+
+	entries := []historyEntry{
+		// Outside the range
+		{Player: "org.mpris.MediaPlayer2.mpd", Started: since.Add(-time.Second), Listened: time.Hour, Artist: "A", Title: "Before"},
+		{Player: "org.mpris.MediaPlayer2.mpd", Started: until, Listened: time.Hour, Artist: "A", Title: "After"},
+
+		{Player: "org.mpris.MediaPlayer2.mpd", Started: since, Listened: 10 * time.Minute, Artist: "A", Album: "X", AlbumArtist: "Various", Genre: "Rock, Pop", Title: "T1"},
+		{Player: "org.mpris.MediaPlayer2.spotify", Identity: "Spotify", Started: since.Add(time.Hour), Listened: 5 * time.Minute, Artist: "B", Album: "Y", Genre: "Pop", Title: "T2"},
+		{Player: "org.mpris.MediaPlayer2.spotify", Identity: "Spotify", Started: since.Add(2 * time.Hour), Listened: 3 * time.Minute, Artist: "C", URL: "file:///c.mp3"},
+	}
+	for _, e := range entries {
+		if err := appendHistory(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s, err := computeStats(since, until, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s.Seconds != 18*60 || s.Plays != 3 {
+		t.Errorf("computeStats() = %ds in %d plays, want %ds in %d plays", s.Seconds, s.Plays, 18*60, 3)
+	}
+
+	totals := []struct {
+		name string
+		got  []statsTotal
+		want []statsTotal
+	}{
+		// Players aren't cut off
+		{"players", s.Players, []statsTotal{{"mpd", 600, 1}, {"Spotify", 480, 2}}},
+		{"artists", s.Artists, []statsTotal{{"A", 600, 1}, {"B", 300, 1}}},
+		// The artist stands in for a missing album artist
+		{"albums", s.Albums, []statsTotal{{"Various – X", 600, 1}, {"B – Y", 300, 1}}},
+		// A track counts fully towards each of its genres
+		{"genres", s.Genres, []statsTotal{{"Pop", 900, 2}, {"Rock", 600, 1}}},
+		{"tracks", s.Tracks, []statsTotal{{"A – T1", 600, 1}, {"B – T2", 300, 1}}},
+	}
+	for _, tt := range totals {
+		if !reflect.DeepEqual(tt.got, tt.want) {
+			t.Errorf("computeStats() %s = %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+
+	var wantHours [24]int64
+	wantHours[0], wantHours[1], wantHours[2] = 600, 300, 180
+	if s.Hours != wantHours {
+		t.Errorf("computeStats() hours = %v, want %v", s.Hours, wantHours)
+	}
+
+	// Everything is kept with top <= 0, and untitled tracks go by their url
+	s, err = computeStats(since, until, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantTracks := []statsTotal{{"A – T1", 600, 1}, {"B – T2", 300, 1}, {"C – file:///c.mp3", 180, 1}}
+	if !reflect.DeepEqual(s.Tracks, wantTracks) {
+		t.Errorf("computeStats() tracks = %v, want %v", s.Tracks, wantTracks)
+	}
+	wantGenres := []statsTotal{{"Pop", 900, 2}, {"Rock", 600, 1}, {"Unknown", 180, 1}}
+	if !reflect.DeepEqual(s.Genres, wantGenres) {
+		t.Errorf("computeStats() genres = %v, want %v", s.Genres, wantGenres)
+	}
+}
+
+func TestComputeStatsWithoutHistory(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+
+	now := time.Now()
+	s, err := computeStats(now.Add(-time.Hour), now, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Plays != 0 || len(s.Players) != 0 {
+		t.Errorf("computeStats() = %+v, want nothing", s)
+	}
+}